@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+	"github.com/krok-o/terraform-provider-krok/pkg/export"
+)
+
+func main() {
+	var (
+		cfg     pkg.Config
+		profile string
+		out     string
+	)
+	flag.StringVar(&cfg.Address, "endpoint", "", "KROK API ENDPOINT (default $KROK_ENDPOINT or http://localhost:9998)")
+	flag.StringVar(&cfg.APIKeyID, "api-key-id", "", "KROK API KEY ID (default $KROK_API_KEY_ID)")
+	flag.StringVar(&cfg.APIKeySecret, "api-key-secret", "", "KROK API KEY SECRET (default $KROK_API_KEY_SECRET)")
+	flag.StringVar(&cfg.Email, "email", "", "KROK EMAIL (default $KROK_EMAIL)")
+	flag.StringVar(&profile, "profile", os.Getenv("KROK_PROFILE"), "Profile of the Krok config file to read the endpoint and credentials from. Flags take precedence.")
+	flag.StringVar(&out, "out", ".", "Directory to write the generated configuration to.")
+	flag.Parse()
+
+	log := zerolog.New(zerolog.ConsoleWriter{
+		Out: os.Stderr,
+	}).With().Timestamp().Logger()
+
+	if profile != "" {
+		p, err := loadProfile(profile)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to load profile.")
+			os.Exit(1)
+		}
+		cfg = cfg.Merge(p)
+	}
+	cfg = cfg.Merge(pkg.Config{
+		Address:      os.Getenv("KROK_ENDPOINT"),
+		APIKeyID:     os.Getenv("KROK_API_KEY_ID"),
+		APIKeySecret: os.Getenv("KROK_API_KEY_SECRET"),
+		Email:        os.Getenv("KROK_EMAIL"),
+	}).Merge(pkg.Config{
+		Address: "http://localhost:9998",
+	})
+
+	if err := run(cfg, out, log); err != nil {
+		log.Error().Err(err).Msg("Failed to export Krok configuration.")
+		os.Exit(1)
+	}
+}
+
+// loadProfile reads the profile called name from the Krok config file.
+func loadProfile(name string) (pkg.Config, error) {
+	file, err := pkg.ProfileFile()
+	if err != nil {
+		return pkg.Config{}, err
+	}
+	return pkg.LoadProfile(file, name)
+}
+
+// run generates the configuration and writes the resulting files into out.
+func run(cfg pkg.Config, out string, log zerolog.Logger) error {
+	client := pkg.NewKrokClient(cfg, log)
+	output, err := export.NewGenerator(client, log).Generate(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	files := []struct {
+		name    string
+		content []byte
+		mode    os.FileMode
+	}{
+		{name: "krok.tf", content: output.Config, mode: 0644},
+		{name: "imports.tf", content: output.Imports, mode: 0644},
+		{name: "import.sh", content: output.ImportScript, mode: 0755},
+		// contains the secrets read from the server, only readable by the current user.
+		{name: "krok.auto.tfvars", content: output.Variables, mode: 0600},
+	}
+	for _, f := range files {
+		p := filepath.Join(out, f.name)
+		if err := os.WriteFile(p, f.content, f.mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", p, err)
+		}
+		log.Info().Str("file", p).Msg("Written.")
+	}
+	return nil
+}
@@ -10,8 +10,9 @@ provider "krok" {
  * Create github platform token.
  */
 resource "krok_platform" "github" {
-  platform_id = 1
-  token = "token"
+  vcs = 1
+  token_wo = "token"
+  token_wo_version = 1
 }
 
 /*
@@ -40,7 +41,7 @@ resource "krok_repository" "skarlso_test" {
   name = "skarlso-test"
   url = "https://github.com/Skarlso/test"
   vcs = 1
-  auth {
+  auth = {
     secret = "secret"
   }
   commands = [krok_command.slack_notification.id]
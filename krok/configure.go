@@ -0,0 +1,227 @@
+package krok
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+)
+
+// providerConfig holds the provider arguments once defaults have been applied.
+type providerConfig struct {
+	endpoint      string
+	apiKeyID      string
+	apiKeySecret  string
+	email         string
+	logLevel      string
+	skipPreflight bool
+	token         string
+	tokenFile     string
+	tokenCommand  string
+	tokenCacheDir string
+
+	requestsPerSecond     float64
+	maxConcurrentRequests int
+	cacheReads            bool
+
+	allowedImageRegistries []string
+	deniedImagePatterns    []string
+	requireDigest          bool
+}
+
+var (
+	// errInvalidImagePolicy is returned if the image policy arguments can't be parsed.
+	errInvalidImagePolicy = errors.New("invalid image policy")
+	// errAPIKeySecretFile is returned if the api key secret can't be loaded from a file.
+	errAPIKeySecretFile = errors.New("invalid api_key_secret_file")
+	// errInvalidProfile is returned if the selected profile can't be loaded.
+	errInvalidProfile = errors.New("invalid profile")
+	// errInvalidAuth is returned if more than one way to authenticate is configured.
+	errInvalidAuth = errors.New("conflicting authentication arguments")
+)
+
+// connectionArguments are the provider arguments which select the Krok server and the credentials,
+// empty if they aren't set.
+type connectionArguments struct {
+	endpoint         string
+	apiKeyID         string
+	apiKeySecret     string
+	apiKeySecretFile string
+	email            string
+	profile          string
+	token            string
+	tokenFile        string
+	tokenCommand     string
+}
+
+// resolveConnection returns the connection settings for args. Every setting comes from the first of:
+// the provider argument, the profile selected by the profile argument or KROK_PROFILE, the environment
+// and the default. How to authenticate, with the API key secret or one of the token modes, is taken as
+// a whole from the first of them which sets any.
+func resolveConnection(args connectionArguments) (pkg.Config, error) {
+	modes := 0
+	for _, m := range []string{args.apiKeySecret + args.apiKeySecretFile, args.token, args.tokenFile, args.tokenCommand} {
+		if m != "" {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return pkg.Config{}, fmt.Errorf("%w: only one of api_key_secret, api_key_secret_file, token, token_file and token_command can be set", errInvalidAuth)
+	}
+	secret, err := readAPIKeySecret(args.apiKeySecret, args.apiKeySecretFile)
+	if err != nil {
+		return pkg.Config{}, err
+	}
+	cfg := pkg.Config{
+		Address:      args.endpoint,
+		APIKeyID:     args.apiKeyID,
+		APIKeySecret: secret,
+		Email:        args.email,
+		Token:        args.token,
+		TokenFile:    args.tokenFile,
+		TokenCommand: args.tokenCommand,
+	}
+	profile := args.profile
+	if profile == "" {
+		profile = os.Getenv("KROK_PROFILE")
+	}
+	if profile != "" {
+		file, err := pkg.ProfileFile()
+		if err != nil {
+			return pkg.Config{}, fmt.Errorf("%w: %w", errInvalidProfile, err)
+		}
+		p, err := pkg.LoadProfile(file, profile)
+		if err != nil {
+			return pkg.Config{}, fmt.Errorf("%w: %w", errInvalidProfile, err)
+		}
+		cfg = mergeConnection(cfg, p)
+	}
+	if f := os.Getenv("KROK_API_KEY_SECRET_FILE"); !hasAuth(cfg) && f != "" {
+		if cfg.APIKeySecret, err = readAPIKeySecret("", f); err != nil {
+			return pkg.Config{}, err
+		}
+	}
+	return mergeConnection(cfg, pkg.Config{
+		Address:      os.Getenv("KROK_ENDPOINT"),
+		APIKeyID:     os.Getenv("KROK_API_KEY_ID"),
+		APIKeySecret: os.Getenv("KROK_API_KEY_SECRET"),
+		Email:        os.Getenv("KROK_EMAIL"),
+		Token:        os.Getenv("KROK_TOKEN"),
+		TokenFile:    os.Getenv("KROK_TOKEN_FILE"),
+		TokenCommand: os.Getenv("KROK_TOKEN_COMMAND"),
+	}).Merge(pkg.Config{
+		Address: "http://localhost:9998",
+	}), nil
+}
+
+// hasAuth returns whether cfg sets how to authenticate.
+func hasAuth(cfg pkg.Config) bool {
+	return cfg.APIKeySecret != "" || cfg.Token != "" || cfg.TokenFile != "" || cfg.TokenCommand != ""
+}
+
+// mergeConnection fills the unset settings of cfg with fallback, except for how to authenticate if cfg already sets it.
+func mergeConnection(cfg, fallback pkg.Config) pkg.Config {
+	if hasAuth(cfg) {
+		fallback.APIKeySecret, fallback.Token, fallback.TokenFile, fallback.TokenCommand = "", "", "", ""
+	}
+	return cfg.Merge(fallback)
+}
+
+// readAPIKeySecret returns secret or the content of file, of which only one can be set.
+func readAPIKeySecret(secret, file string) (string, error) {
+	if secret != "" && file != "" {
+		return "", fmt.Errorf("%w: only one of api_key_secret and api_key_secret_file can be set", errAPIKeySecretFile)
+	}
+	if file == "" {
+		return secret, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errAPIKeySecretFile, err)
+	}
+	secret = strings.TrimSpace(string(content))
+	if secret == "" {
+		return "", fmt.Errorf("%w: %s is empty", errAPIKeySecretFile, file)
+	}
+	return secret, nil
+}
+
+// key identifies cfg among the configured clients. Both providers have to end up with the same
+// key, whether a list is empty or not set.
+func (cfg providerConfig) key() string {
+	return strings.Join([]string{
+		strconv.Quote(cfg.endpoint),
+		strconv.Quote(cfg.apiKeyID),
+		strconv.Quote(cfg.apiKeySecret),
+		strconv.Quote(cfg.email),
+		strconv.Quote(cfg.logLevel),
+		strconv.FormatBool(cfg.skipPreflight),
+		strconv.Quote(cfg.token),
+		strconv.Quote(cfg.tokenFile),
+		strconv.Quote(cfg.tokenCommand),
+		strconv.Quote(cfg.tokenCacheDir),
+		strconv.FormatFloat(cfg.requestsPerSecond, 'g', -1, 64),
+		strconv.Itoa(cfg.maxConcurrentRequests),
+		strconv.FormatBool(cfg.cacheReads),
+		fmt.Sprintf("%q", cfg.allowedImageRegistries),
+		fmt.Sprintf("%q", cfg.deniedImagePatterns),
+		strconv.FormatBool(cfg.requireDigest),
+	}, ",")
+}
+
+// configured is the result of configuring a client for a given provider configuration.
+type configured struct {
+	client *pkg.KrokClient
+	err    error
+}
+
+var (
+	clientsLock sync.Mutex
+	clients     = map[string]configured{}
+)
+
+// configureClient returns the Krok client for cfg. The SDK and the framework provider are
+// served side by side and are both configured by Terraform, so the client and the outcome
+// of the preflight check are shared between them.
+func configureClient(ctx context.Context, cfg providerConfig) (*pkg.KrokClient, error) {
+	clientsLock.Lock()
+	defer clientsLock.Unlock()
+	key := cfg.key()
+	if c, ok := clients[key]; ok {
+		return c.client, c.err
+	}
+	policy, err := pkg.NewImagePolicy(cfg.allowedImageRegistries, cfg.deniedImagePatterns, cfg.requireDigest)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", errInvalidImagePolicy, err)
+		clients[key] = configured{err: err}
+		return nil, err
+	}
+	log := pkg.NewLogger(cfg.logLevel, os.Stderr)
+	client := pkg.NewKrokClient(pkg.Config{
+		Address:               cfg.endpoint,
+		APIKeyID:              cfg.apiKeyID,
+		APIKeySecret:          cfg.apiKeySecret,
+		Email:                 cfg.email,
+		Token:                 cfg.token,
+		TokenFile:             cfg.tokenFile,
+		TokenCommand:          cfg.tokenCommand,
+		TokenCacheDir:         cfg.tokenCacheDir,
+		RequestsPerSecond:     cfg.requestsPerSecond,
+		MaxConcurrentRequests: cfg.maxConcurrentRequests,
+		CacheReads:            cfg.cacheReads,
+	}, log)
+	client.ImagePolicy = policy
+	if !cfg.skipPreflight {
+		err = client.Preflight(ctx, cfg.endpoint)
+	}
+	if err != nil {
+		client = nil
+	}
+	clients[key] = configured{client: client, err: err}
+	return client, err
+}
@@ -1,9 +1,11 @@
 package krok
 
 import (
+	"context"
 	"strconv"
 
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 
 	"github.com/krok-o/krok/pkg/models"
 
@@ -19,7 +21,10 @@ const (
 // dataSourceKrokCommand defines a Command datasource terraform type.
 func dataSourceKrokCommand() *schema.Resource {
 	return &schema.Resource{
-		Read: dataSourceKrokCommandRead,
+		ReadContext: dataSourceKrokCommandRead,
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(defaultReadTimeout),
+		},
 
 		Schema: map[string]*schema.Schema{
 			commandIdFieldName: {
@@ -35,17 +40,17 @@ func dataSourceKrokCommand() *schema.Resource {
 }
 
 // dataSourceKrokCommandRead reloads the resource object from the Terraform store.
-func dataSourceKrokCommandRead(data *schema.ResourceData, m interface{}) error {
+func dataSourceKrokCommandRead(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*pkg.KrokClient)
 	cid := data.Get(commandIdFieldName).(int)
-	command, err := client.CommandClient.Get(cid)
+	command, err := client.CommandClient.Get(ctx, cid)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	for k, v := range flattenCommandObject(command) {
 		if err := data.Set(k, v); err != nil {
-			return err
+			return diag.FromErr(err)
 		}
 	}
 	data.SetId(strconv.Itoa(command.ID))
@@ -1,9 +1,11 @@
 package krok
 
 import (
+	"context"
 	"strconv"
 
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 
 	"github.com/krok-o/krok/pkg/models"
 
@@ -19,7 +21,10 @@ const (
 // dataSourceKrokPlatform defines a Platform datasource terraform type.
 func dataSourceKrokPlatform() *schema.Resource {
 	return &schema.Resource{
-		Read: dataSourceKrokPlatformRead,
+		ReadContext: dataSourceKrokPlatformRead,
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(defaultReadTimeout),
+		},
 
 		Schema: map[string]*schema.Schema{
 			platformIdFieldName: {
@@ -35,17 +40,17 @@ func dataSourceKrokPlatform() *schema.Resource {
 }
 
 // dataSourceKrokPlatformRead reloads the resource object from the terraform store.
-func dataSourceKrokPlatformRead(data *schema.ResourceData, m interface{}) error {
+func dataSourceKrokPlatformRead(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*pkg.KrokClient)
 	cid := data.Get(platformIdFieldName).(int)
-	platform, err := client.PlatformClient.Get(cid)
+	platform, err := client.PlatformClient.Get(ctx, cid)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	for k, v := range flattenPlatformObject(platform) {
 		if err := data.Set(k, v); err != nil {
-			return err
+			return diag.FromErr(err)
 		}
 	}
 	data.SetId(strconv.Itoa(platform.ID))
@@ -1,7 +1,9 @@
 package krok
 
 import (
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 
 	"github.com/krok-o/krok/pkg/models"
 
@@ -18,7 +20,10 @@ const (
 // dataSourceKrokPlatform defines a Platform datasource terraform type.
 func dataSourceKrokPlatforms() *schema.Resource {
 	return &schema.Resource{
-		Read: dataSourceKrokPlatformsRead,
+		ReadContext: dataSourceKrokPlatformsRead,
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(defaultReadTimeout),
+		},
 
 		Schema: map[string]*schema.Schema{
 			platformsPlatformsFieldName: {
@@ -42,16 +47,16 @@ func dataSourceKrokPlatforms() *schema.Resource {
 }
 
 // dataSourceKrokPlatformsRead reloads the resource object from the terraform store.
-func dataSourceKrokPlatformsRead(data *schema.ResourceData, m interface{}) error {
+func dataSourceKrokPlatformsRead(ctx context.Context, data *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*pkg.KrokClient)
-	platforms, err := client.PlatformClient.List()
+	platforms, err := client.PlatformClient.List(ctx)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	for k, v := range flattenPlatformsObject(platforms) {
 		if err := data.Set(k, v); err != nil {
-			return err
+			return diag.FromErr(err)
 		}
 	}
 	data.SetId(uniqueResourceID())
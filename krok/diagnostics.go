@@ -0,0 +1,27 @@
+package krok
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// missingFieldDiag returns an error diagnostic for a field which could not be found or parsed.
+func missingFieldDiag(field string) diag.Diagnostics {
+	return diag.Diagnostics{{
+		Severity:      diag.Error,
+		Summary:       fmt.Sprintf("unable to find or parse field %s", field),
+		AttributePath: cty.GetAttrPath(field),
+	}}
+}
+
+// attributeDiag returns an error diagnostic for err pointing at the given attribute.
+func attributeDiag(summary string, err error, path cty.Path) diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity:      diag.Error,
+		Summary:       summary,
+		Detail:        err.Error(),
+		AttributePath: path,
+	}
+}
@@ -0,0 +1,81 @@
+package krok
+
+import (
+	"strings"
+)
+
+// platformEvents are the webhook events a repository can subscribe to, keyed by the platform name Krok reports.
+// GitHub, Gitea and Bitbucket use their own event names, for GitLab Krok expects the names of the hook options.
+var platformEvents = map[string][]string{
+	"github": {
+		"*", "branch_protection_rule", "check_run", "check_suite", "code_scanning_alert", "commit_comment",
+		"create", "delete", "deploy_key", "deployment", "deployment_status", "discussion", "discussion_comment",
+		"fork", "gollum", "installation", "installation_repositories", "issue_comment", "issues", "label",
+		"member", "membership", "merge_group", "meta", "milestone", "organization", "org_block", "package",
+		"page_build", "ping", "project", "project_card", "project_column", "public", "pull_request",
+		"pull_request_review", "pull_request_review_comment", "pull_request_review_thread", "push",
+		"registry_package", "release", "repository", "repository_import", "repository_vulnerability_alert",
+		"security_advisory", "star", "status", "team", "team_add", "watch", "workflow_dispatch", "workflow_job",
+		"workflow_run",
+	},
+	"gitlab": {
+		"PushEvents", "TagPushEvents", "IssuesEvents", "ConfidentialIssuesEvents", "NoteEvents",
+		"ConfidentialNoteEvents", "MergeRequestsEvents", "JobEvents", "PipelineEvents", "WikiPageEvents",
+		"DeploymentEvents", "ReleasesEvents",
+	},
+	"gitea": {
+		"create", "delete", "fork", "push", "issues", "issue_assign", "issue_label", "issue_milestone",
+		"issue_comment", "pull_request", "pull_request_assign", "pull_request_label", "pull_request_milestone",
+		"pull_request_comment", "pull_request_review_approved", "pull_request_review_rejected",
+		"pull_request_review_comment", "pull_request_sync", "wiki", "repository", "release",
+	},
+	"bitbucket": {
+		"repo:push", "repo:fork", "repo:updated", "repo:commit_comment_created", "repo:commit_status_created",
+		"repo:commit_status_updated", "issue:created", "issue:updated", "issue:comment_created",
+		"pullrequest:created", "pullrequest:updated", "pullrequest:approved", "pullrequest:unapproved",
+		"pullrequest:fulfilled", "pullrequest:rejected", "pullrequest:comment_created",
+		"pullrequest:comment_updated", "pullrequest:comment_deleted",
+	},
+}
+
+// maxSuggestionDistance is the largest edit distance for which an event is suggested as a replacement.
+const maxSuggestionDistance = 3
+
+// suggestEvent returns the allowed event closest to event, or an empty string if none is close.
+func suggestEvent(event string, allowed []string) string {
+	var (
+		suggestion string
+		best       = maxSuggestionDistance + 1
+	)
+	for _, a := range allowed {
+		if strings.EqualFold(a, event) {
+			return a
+		}
+		if d := levenshtein(strings.ToLower(event), strings.ToLower(a)); d < best {
+			best = d
+			suggestion = a
+		}
+	}
+	return suggestion
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,125 @@
+package krok
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/krok/pkg/models"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+	"github.com/krok-o/terraform-provider-krok/pkg/clients/fake"
+)
+
+func TestLevenshtein(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"push", "push", 0},
+		{"", "push", 4},
+		{"pushh", "push", 1},
+		{"psuh", "push", 2},
+		{"kitten", "sitting", 3},
+	} {
+		if got := levenshtein(tc.a, tc.b); got != tc.want {
+			t.Errorf("levenshtein(%q, %q) = %d, expected %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestSuggestEvent(t *testing.T) {
+	for _, tc := range []struct {
+		event    string
+		platform string
+		want     string
+	}{
+		{"pushh", "github", "push"},
+		{"PUSH", "github", "push"},
+		{"pull-request", "github", "pull_request"},
+		{"pushevents", "gitlab", "PushEvents"},
+		{"repo:psh", "bitbucket", "repo:push"},
+		{"deployment_created", "gitea", ""},
+		{"something else", "github", ""},
+	} {
+		if got := suggestEvent(tc.event, platformEvents[tc.platform]); got != tc.want {
+			t.Errorf("suggestEvent(%q) for %s = %q, expected %q", tc.event, tc.platform, got, tc.want)
+		}
+	}
+}
+
+func TestValidateEvents(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	r := &repositoryResource{client: pkg.NewKrokClient(pkg.Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: fake.APIKeySecret,
+	}, zerolog.Nop())}
+
+	events := func(values ...string) types.List {
+		elements := make([]attr.Value, 0, len(values))
+		for _, v := range values {
+			elements = append(elements, types.StringValue(v))
+		}
+		return types.ListValueMust(types.StringType, elements)
+	}
+	for _, tc := range []struct {
+		name   string
+		vcs    int
+		events types.List
+		// errors are the expected error details, warnings the expected warning summaries.
+		errors, warnings []string
+	}{
+		{name: "valid github events", vcs: models.GITHUB, events: events("push", "pull_request")},
+		{name: "valid gitlab events", vcs: models.GITLAB, events: events("PushEvents")},
+		{
+			name:   "typo",
+			vcs:    models.GITHUB,
+			events: events("push", "pushh"),
+			errors: []string{`"pushh" is not a webhook event of github. Did you mean "push"?`},
+		},
+		{
+			name:   "event of another platform",
+			vcs:    models.GITHUB,
+			events: events("PushEvents"),
+			errors: []string{`"PushEvents" is not a webhook event of github.`},
+		},
+		{
+			name:   "unknown events are skipped",
+			vcs:    models.GITHUB,
+			events: types.ListValueMust(types.StringType, []attr.Value{types.StringUnknown()}),
+		},
+		{
+			name:     "unknown platform",
+			vcs:      100,
+			events:   events("push"),
+			warnings: []string{"Unable to validate events"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var diags diag.Diagnostics
+			r.validateEvents(context.Background(), types.Int64Value(int64(tc.vcs)), tc.events, &diags)
+			var errors, warnings []string
+			for _, d := range diags.Errors() {
+				errors = append(errors, d.Detail())
+			}
+			for _, d := range diags.Warnings() {
+				warnings = append(warnings, d.Summary())
+			}
+			if strings.Join(errors, "\n") != strings.Join(tc.errors, "\n") {
+				t.Errorf("expected errors %q, got %q", tc.errors, errors)
+			}
+			if strings.Join(warnings, "\n") != strings.Join(tc.warnings, "\n") {
+				t.Errorf("expected warnings %q, got %q", tc.warnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package krok
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/rs/zerolog"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/krok-o/krok/pkg/models"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+	"github.com/krok-o/terraform-provider-krok/pkg/clients/fake"
+	"github.com/krok-o/terraform-provider-krok/pkg/export"
+)
+
+// parseHCL parses generated HCL, failing the test if it's invalid.
+func parseHCL(t *testing.T, name string, src []byte) *hclsyntax.Body {
+	t.Helper()
+	file, diags := hclsyntax.ParseConfig(src, name, hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("%s is invalid: %s", name, diags)
+	}
+	return file.Body.(*hclsyntax.Body)
+}
+
+// ctyStrings returns the elements of a list or tuple as strings, sorted if the list is used as a set.
+func ctyStrings(t *testing.T, v cty.Value, set bool) []string {
+	t.Helper()
+	var values []string
+	for it := v.ElementIterator(); it.Next(); {
+		_, e := it.Element()
+		if e.Type() == cty.Number {
+			values = append(values, e.AsBigFloat().String())
+		} else {
+			values = append(values, e.AsString())
+		}
+	}
+	if set {
+		sort.Strings(values)
+	}
+	return values
+}
+
+// TestExportImportIsClean imports every resource krok-export generates and checks that the imported state
+// matches the generated configuration, so the first plan after the import is clean.
+func TestExportImportIsClean(t *testing.T) {
+	ctx := context.Background()
+	server := fake.NewServer()
+	defer server.Close()
+	github := models.SupportedPlatforms[models.GITHUB]
+	server.AddCommand(&models.Command{ID: 1, Name: "Slack Notification", Image: "krokhook/slack-notification:v0.0.1", Schedule: "@hourly", Enabled: true, Platforms: []models.Platform{github}})
+	server.AddCommand(&models.Command{ID: 2, Name: "slack-notification", Image: "krokhook/slack-notification:v0.0.2"})
+	server.AddRepository(&models.Repository{
+		ID:       1,
+		Name:     "app",
+		URL:      "https://github.com/krok-o/app",
+		VCS:      models.GITHUB,
+		Events:   []string{"push", "pull_request"},
+		Auth:     &models.Auth{Secret: "webhook-secret"},
+		Commands: []*models.Command{{ID: 2}, {ID: 1}},
+	})
+	server.AddRepository(&models.Repository{
+		ID:     2,
+		Name:   "gitlab app",
+		URL:    "https://gitlab.com/krok-o/app",
+		VCS:    models.GITLAB,
+		Events: []string{"PushEvents"},
+		Auth:   &models.Auth{Secret: "gitlab-secret"},
+		GitLab: &models.GitLab{ProjectID: 10},
+	})
+	client := pkg.NewKrokClient(pkg.Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: fake.APIKeySecret,
+	}, zerolog.Nop())
+	output, err := export.NewGenerator(client, zerolog.Nop()).Generate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the generated references are resolved with the imported IDs and the generated variable values.
+	ids := make(map[string]map[string]string)
+	for _, block := range parseHCL(t, "imports.tf", output.Imports).Blocks {
+		to, diags := hcl.AbsTraversalForExpr(block.Body.Attributes["to"].Expr)
+		if diags.HasErrors() {
+			t.Fatal(diags)
+		}
+		id, diags := block.Body.Attributes["id"].Expr.Value(nil)
+		if diags.HasErrors() {
+			t.Fatal(diags)
+		}
+		kind, name := to.RootName(), to[1].(hcl.TraverseAttr).Name
+		if ids[kind] == nil {
+			ids[kind] = make(map[string]string)
+		}
+		ids[kind][name] = id.AsString()
+	}
+	variables := make(map[string]cty.Value)
+	attributes, diags := parseHCL(t, "krok.auto.tfvars", output.Variables).JustAttributes()
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	for name, a := range attributes {
+		variables[name], _ = a.Expr.Value(nil)
+	}
+	commands := make(map[string]cty.Value)
+	for name, id := range ids["krok_command"] {
+		commands[name] = cty.ObjectVal(map[string]cty.Value{"id": cty.StringVal(id)})
+	}
+	evalCtx := &hcl.EvalContext{Variables: map[string]cty.Value{
+		"var":          cty.ObjectVal(variables),
+		"krok_command": cty.ObjectVal(commands),
+	}}
+
+	checked := 0
+	for _, block := range parseHCL(t, "krok.tf", output.Config).Blocks {
+		if block.Type != "resource" {
+			continue
+		}
+		kind, name := block.Labels[0], block.Labels[1]
+		config := make(map[string]cty.Value)
+		for attr, a := range block.Body.Attributes {
+			v, diags := a.Expr.Value(evalCtx)
+			if diags.HasErrors() {
+				t.Fatalf("%s.%s.%s: %s", kind, name, attr, diags)
+			}
+			config[attr] = v
+		}
+		id, ok := ids[kind][name]
+		if !ok {
+			t.Errorf("%s.%s has no import", kind, name)
+			continue
+		}
+		t.Run(kind+"."+name, func(t *testing.T) {
+			switch kind {
+			case "krok_command":
+				checked++
+				d := importCommand(t, client, id)
+				for _, attr := range []string{commandResourceNameFieldName, commandResourceImageFieldName, commandResourceScheduleFieldName} {
+					if want, ok := config[attr]; ok && d.Get(attr) != want.AsString() {
+						t.Errorf("%s: expected %s, got %v", attr, want.AsString(), d.Get(attr))
+					}
+				}
+				if d.Get(commandResourceEnabledFieldName) != config[commandResourceEnabledFieldName].True() {
+					t.Errorf("enabled: expected %v, got %v", config[commandResourceEnabledFieldName], d.Get(commandResourceEnabledFieldName))
+				}
+				want := fmt.Sprint(ctyStrings(t, config[commandResourcePlatformsFieldName], false))
+				if got := fmt.Sprint(d.Get(commandResourcePlatformsFieldName)); got != want {
+					t.Errorf("platforms: expected %s, got %s", want, got)
+				}
+			case "krok_repository":
+				checked++
+				data := importRepository(t, client, id)
+				if data.Name.ValueString() != config[repoNameFieldName].AsString() || data.URL.ValueString() != config[repoURLFieldName].AsString() {
+					t.Errorf("expected name %s and url %s, got %s and %s", config[repoNameFieldName], config[repoURLFieldName], data.Name, data.URL)
+				}
+				var commands []int64
+				data.Commands.ElementsAs(ctx, &commands, false)
+				got := make([]string, 0, len(commands))
+				for _, c := range commands {
+					got = append(got, fmt.Sprint(c))
+				}
+				sort.Strings(got)
+				if want := ctyStrings(t, config[repoCommandsFieldName], true); fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("commands: expected %v, got %v", want, got)
+				}
+				var events []string
+				data.Events.ElementsAs(ctx, &events, false)
+				if want := ctyStrings(t, config[repoEventsFieldName], false); fmt.Sprint(events) != fmt.Sprint(want) {
+					t.Errorf("events: expected %v, got %v", want, events)
+				}
+				if want := config[repoAuthFieldName].GetAttr(repoAuthSecretFieldName).AsString(); data.Auth == nil || data.Auth.Secret.ValueString() != want {
+					t.Errorf("auth: expected secret %q, got %+v", want, data.Auth)
+				}
+				gitlab, ok := config[repoGitlabFieldName]
+				switch {
+				case !ok && data.GitLab != nil:
+					t.Errorf("gitlab: expected none, got %+v", data.GitLab)
+				case ok && (data.GitLab == nil || fmt.Sprint(data.GitLab.ProjectID.ValueInt64()) != gitlab.GetAttr(repoGitlabProjectIDFieldName).AsBigFloat().String()):
+					t.Errorf("gitlab: expected %v, got %+v", gitlab, data.GitLab)
+				}
+			}
+		})
+	}
+	if checked != 4 {
+		t.Errorf("expected two commands and two repositories to be checked, got %d", checked)
+	}
+}
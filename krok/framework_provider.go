@@ -0,0 +1,271 @@
+package krok
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/float64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-mux/tf5to6server"
+	"github.com/hashicorp/terraform-plugin-mux/tf6muxserver"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+)
+
+// frameworkProvider serves the resources which have been ported to terraform-plugin-framework.
+// It is muxed with the SDK provider, so its schema has to stay identical to the one of Provider.
+type frameworkProvider struct {
+	version string
+}
+
+// frameworkProviderModel maps the provider arguments.
+type frameworkProviderModel struct {
+	APIKeyID         types.String `tfsdk:"api_key_id"`
+	APIKeySecret     types.String `tfsdk:"api_key_secret"`
+	APIKeySecretFile types.String `tfsdk:"api_key_secret_file"`
+	Email            types.String `tfsdk:"email"`
+	Profile          types.String `tfsdk:"profile"`
+	Token            types.String `tfsdk:"token"`
+	TokenFile        types.String `tfsdk:"token_file"`
+	TokenCommand     types.String `tfsdk:"token_command"`
+	TokenCacheDir    types.String `tfsdk:"token_cache_dir"`
+	Endpoint         types.String `tfsdk:"endpoint"`
+	LogLevel         types.String `tfsdk:"log_level"`
+	SkipPreflight    types.Bool   `tfsdk:"skip_preflight"`
+
+	RequestsPerSecond     types.Float64 `tfsdk:"requests_per_second"`
+	MaxConcurrentRequests types.Int64   `tfsdk:"max_concurrent_requests"`
+	CacheReads            types.Bool    `tfsdk:"cache_reads"`
+
+	AllowedImageRegistries []string   `tfsdk:"allowed_image_registries"`
+	DeniedImagePatterns    []string   `tfsdk:"denied_image_patterns"`
+	RequireDigest          types.Bool `tfsdk:"require_digest"`
+}
+
+// NewFrameworkProvider defines the Krok Terraform provider for resources built on terraform-plugin-framework.
+func NewFrameworkProvider(version string) func() provider.Provider {
+	return func() provider.Provider {
+		return &frameworkProvider{version: version}
+	}
+}
+
+func (p *frameworkProvider) Metadata(_ context.Context, _ provider.MetadataRequest, resp *provider.MetadataResponse) {
+	resp.TypeName = "krok"
+	resp.Version = p.version
+}
+
+// Schema mirrors the schema of the SDK provider. Required arguments with an environment
+// default are optional on the wire, and validation is left to the SDK provider.
+func (p *frameworkProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *provider.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"api_key_id": schema.StringAttribute{
+				Optional:    true,
+				Description: "KROK API KEY ID",
+			},
+			"api_key_secret": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: "KROK API KEY SECRET",
+			},
+			"api_key_secret_file": schema.StringAttribute{
+				Optional:    true,
+				Description: "Path to a file containing the KROK API KEY SECRET, as alternative to api_key_secret.",
+			},
+			"email": schema.StringAttribute{
+				Optional:    true,
+				Description: "KROK EMAIL",
+			},
+			"endpoint": schema.StringAttribute{
+				Optional:    true,
+				Description: "KROK API ENDPOINT",
+			},
+			"profile": schema.StringAttribute{
+				Optional:    true,
+				Description: "Profile of the Krok config file ~/.krok/config to read the endpoint and credentials from. Arguments set on the provider take precedence.",
+			},
+			"token": schema.StringAttribute{
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Token issued by Krok, for example for a user, used instead of exchanging the API key for one.",
+			},
+			"token_file": schema.StringAttribute{
+				Optional:    true,
+				Description: "Path to a file containing a token, which is read again when the token is rejected.",
+			},
+			"token_command": schema.StringAttribute{
+				Optional:    true,
+				Description: "Shell command printing a token, like a single sign-on helper. It runs again when the token is rejected.",
+			},
+			"token_cache_dir": schema.StringAttribute{
+				Optional:    true,
+				Description: "Directory to cache tokens from the API key exchange in, so they are reused across Terraform runs until they expire. Disabled if empty.",
+			},
+			"requests_per_second": schema.Float64Attribute{
+				Optional:    true,
+				Validators:  []validator.Float64{float64validator.AtLeast(0)},
+				Description: "Maximum number of requests per second sent to Krok, spread out evenly. Unlimited if 0.",
+			},
+			"max_concurrent_requests": schema.Int64Attribute{
+				Optional:    true,
+				Validators:  []validator.Int64{int64validator.AtLeast(0)},
+				Description: "Maximum number of requests to Krok in flight at the same time. Unlimited if 0.",
+			},
+			"cache_reads": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Cache reads of commands, platforms and repositories for the duration of a Terraform run. Changes made by the provider invalidate the cache, changes made elsewhere during the run are missed.",
+			},
+			"log_level": schema.StringAttribute{
+				Optional:    true,
+				Description: "Log level of the provider. Defaults to the level set by TF_LOG.",
+			},
+			"skip_preflight": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Skip checking the endpoint and credentials when the provider is configured.",
+			},
+			"allowed_image_registries": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Registries, optionally with a path like registry.example.com/team, which command images have to come from. Empty allows every registry.",
+			},
+			"denied_image_patterns": schema.ListAttribute{
+				Optional:    true,
+				ElementType: types.StringType,
+				Description: "Regular expressions matched against fully qualified command images, like docker.io/library/ubuntu:latest. Matching images are rejected.",
+			},
+			"require_digest": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Reject command images which are referenced by tag only instead of by digest.",
+			},
+		},
+	}
+}
+
+func (p *frameworkProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	var data frameworkProviderModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	conn, err := resolveConnection(connectionArguments{
+		endpoint:         data.Endpoint.ValueString(),
+		apiKeyID:         data.APIKeyID.ValueString(),
+		apiKeySecret:     data.APIKeySecret.ValueString(),
+		apiKeySecretFile: data.APIKeySecretFile.ValueString(),
+		email:            data.Email.ValueString(),
+		profile:          data.Profile.ValueString(),
+		token:            data.Token.ValueString(),
+		tokenFile:        data.TokenFile.ValueString(),
+		tokenCommand:     data.TokenCommand.ValueString(),
+	})
+	if err != nil {
+		if summary, attribute, ok := configureFailure(err); ok {
+			resp.Diagnostics.AddAttributeError(path.Root(attribute), summary, err.Error())
+		} else {
+			resp.Diagnostics.AddError("Failed to configure the Krok client", err.Error())
+		}
+		return
+	}
+	skipPreflight := data.SkipPreflight.ValueBool()
+	if data.SkipPreflight.IsNull() {
+		skipPreflight, _ = strconv.ParseBool(os.Getenv("KROK_SKIP_PREFLIGHT"))
+	}
+	client, err := configureClient(ctx, providerConfig{
+		endpoint:      conn.Address,
+		apiKeyID:      conn.APIKeyID,
+		apiKeySecret:  conn.APIKeySecret,
+		email:         conn.Email,
+		token:         conn.Token,
+		tokenFile:     conn.TokenFile,
+		tokenCommand:  conn.TokenCommand,
+		tokenCacheDir: stringOrEnv(data.TokenCacheDir, "KROK_TOKEN_CACHE_DIR", ""),
+		logLevel:      stringOrEnv(data.LogLevel, "KROK_LOG_LEVEL", ""),
+		skipPreflight: skipPreflight,
+
+		requestsPerSecond:     data.RequestsPerSecond.ValueFloat64(),
+		maxConcurrentRequests: int(data.MaxConcurrentRequests.ValueInt64()),
+		cacheReads:            data.CacheReads.ValueBool(),
+
+		allowedImageRegistries: data.AllowedImageRegistries,
+		deniedImagePatterns:    data.DeniedImagePatterns,
+		requireDigest:          data.RequireDigest.ValueBool(),
+	})
+	if err != nil {
+		if summary, attribute, ok := configureFailure(err); ok {
+			resp.Diagnostics.AddAttributeError(path.Root(attribute), summary, err.Error())
+		} else {
+			resp.Diagnostics.AddError("Failed to configure the Krok client", err.Error())
+		}
+		return
+	}
+	resp.ResourceData = client
+	resp.DataSourceData = client
+}
+
+func (p *frameworkProvider) Resources(_ context.Context) []func() resource.Resource {
+	return []func() resource.Resource{
+		NewRepositoryResource,
+		NewPlatformResource,
+		NewCommandArchiveResource,
+		NewCommandRepositoryAttachmentResource,
+		NewCommandPlatformAttachmentResource,
+		NewCommandSettingsResource,
+	}
+}
+
+func (p *frameworkProvider) DataSources(_ context.Context) []func() datasource.DataSource {
+	return nil
+}
+
+// configuredClient returns the Krok client handed to framework resources by Configure.
+// It's nil while the provider hasn't been configured yet, e.g. during validation.
+func configuredClient(providerData any, diags *diag.Diagnostics) *pkg.KrokClient {
+	if providerData == nil {
+		return nil
+	}
+	client, ok := providerData.(*pkg.KrokClient)
+	if !ok {
+		diags.AddError("Unexpected provider data", fmt.Sprintf("expected *pkg.KrokClient, got %T", providerData))
+	}
+	return client
+}
+
+// stringOrEnv returns the configured value, or the value of the environment variable key if it isn't set.
+func stringOrEnv(v types.String, key, def string) string {
+	if !v.IsNull() && !v.IsUnknown() {
+		return v.ValueString()
+	}
+	if e := os.Getenv(key); e != "" {
+		return e
+	}
+	return def
+}
+
+// ProviderServer serves the framework provider next to the SDK provider, which is
+// upgraded to protocol 6 to be muxed with it.
+func ProviderServer(ctx context.Context, version string) (func() tfprotov6.ProviderServer, error) {
+	upgradedSdkServer, err := tf5to6server.UpgradeServer(ctx, Provider().GRPCProvider)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade the sdk provider: %w", err)
+	}
+	muxServer, err := tf6muxserver.NewMuxServer(ctx,
+		providerserver.NewProtocol6(NewFrameworkProvider(version)()),
+		func() tfprotov6.ProviderServer { return upgradedSdkServer },
+	)
+	if err != nil {
+		return nil, err
+	}
+	return muxServer.ProviderServer, nil
+}
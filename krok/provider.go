@@ -1,14 +1,17 @@
 package krok
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
 	"strings"
 	"sync/atomic"
 	"time"
 
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
-	"github.com/rs/zerolog"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	"github.com/krok-o/terraform-provider-krok/pkg"
 )
@@ -17,35 +20,112 @@ import (
 func Provider() *schema.Provider {
 	return &schema.Provider{
 		Schema: map[string]*schema.Schema{
+			// the environment defaults of the connection arguments are applied by resolveConnection,
+			// after the profile.
 			"api_key_id": {
 				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: schema.EnvDefaultFunc("KROK_API_KEY_ID", ""),
+				Optional:    true,
 				Description: "KROK API KEY ID",
 			},
 			"api_key_secret": {
 				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: schema.EnvDefaultFunc("KROK_API_KEY_SECRET", ""),
+				Optional:    true,
+				Sensitive:   true,
 				Description: "KROK API KEY SECRET",
 			},
+			"api_key_secret_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to a file containing the KROK API KEY SECRET, as alternative to api_key_secret.",
+			},
 			"email": {
 				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: schema.EnvDefaultFunc("KROK_EMAIL", ""),
+				Optional:    true,
 				Description: "KROK EMAIL",
 			},
 			"endpoint": {
 				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: schema.EnvDefaultFunc("KROK_ENDPOINT", "http://localhost:9998"),
+				Optional:    true,
 				Description: "KROK API ENDPOINT",
 			},
+			"profile": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Profile of the Krok config file ~/.krok/config to read the endpoint and credentials from. Arguments set on the provider take precedence.",
+			},
+			"token": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Token issued by Krok, for example for a user, used instead of exchanging the API key for one.",
+			},
+			"token_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to a file containing a token, which is read again when the token is rejected.",
+			},
+			"token_command": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Shell command printing a token, like a single sign-on helper. It runs again when the token is rejected.",
+			},
+			"token_cache_dir": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KROK_TOKEN_CACHE_DIR", ""),
+				Description: "Directory to cache tokens from the API key exchange in, so they are reused across Terraform runs until they expire. Disabled if empty.",
+			},
+			"requests_per_second": {
+				Type:             schema.TypeFloat,
+				Optional:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.FloatAtLeast(0)),
+				Description:      "Maximum number of requests per second sent to Krok, spread out evenly. Unlimited if 0.",
+			},
+			"max_concurrent_requests": {
+				Type:             schema.TypeInt,
+				Optional:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.IntAtLeast(0)),
+				Description:      "Maximum number of requests to Krok in flight at the same time. Unlimited if 0.",
+			},
+			"cache_reads": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Cache reads of commands, platforms and repositories for the duration of a Terraform run. Changes made by the provider invalidate the cache, changes made elsewhere during the run are missed.",
+			},
+			"log_level": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				DefaultFunc:      schema.EnvDefaultFunc("KROK_LOG_LEVEL", ""),
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringInSlice(pkg.LogLevels, true)),
+				Description:      "Log level of the provider. Defaults to the level set by TF_LOG.",
+			},
+			"skip_preflight": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KROK_SKIP_PREFLIGHT", false),
+				Description: "Skip checking the endpoint and credentials when the provider is configured.",
+			},
+			"allowed_image_registries": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Registries, optionally with a path like registry.example.com/team, which command images have to come from. Empty allows every registry.",
+			},
+			"denied_image_patterns": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Regular expressions matched against fully qualified command images, like docker.io/library/ubuntu:latest. Matching images are rejected.",
+			},
+			"require_digest": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Reject command images which are referenced by tag only instead of by digest.",
+			},
 		},
+		// krok_repository and krok_platform are served by the framework provider.
 		ResourcesMap: map[string]*schema.Resource{
-			"krok_repository":      resourceRepository(),
 			"krok_command":         resourceCommand(),
-			"krok_platform":        resourcePlatform(),
 			"krok_command_setting": resourceCommandSettings(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
@@ -53,25 +133,103 @@ func Provider() *schema.Provider {
 			"krok_platform":  dataSourceKrokPlatform(),
 			"krok_platforms": dataSourceKrokPlatforms(),
 		},
-		ConfigureFunc: providerConfigure,
+		ConfigureContextFunc: providerConfigure,
 	}
 }
 
-func providerConfigure(d *schema.ResourceData) (interface{}, error) {
-	// Set up the main client.
-	log := zerolog.New(zerolog.ConsoleWriter{
-		Out: os.Stderr,
-	}).With().Timestamp().Logger()
-	client := pkg.NewKrokClient(pkg.Config{
-		Address:      d.Get("endpoint").(string),
-		APIKeyID:     d.Get("api_key_id").(string),
-		APIKeySecret: d.Get("api_key_secret").(string),
-		Email:        d.Get("email").(string),
-	}, log)
+func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+	conn, err := resolveConnection(connectionArguments{
+		endpoint:         d.Get("endpoint").(string),
+		apiKeyID:         d.Get("api_key_id").(string),
+		apiKeySecret:     d.Get("api_key_secret").(string),
+		apiKeySecretFile: d.Get("api_key_secret_file").(string),
+		email:            d.Get("email").(string),
+		profile:          d.Get("profile").(string),
+		token:            d.Get("token").(string),
+		tokenFile:        d.Get("token_file").(string),
+		tokenCommand:     d.Get("token_command").(string),
+	})
+	if err != nil {
+		return nil, configureDiagnostics(err)
+	}
+	client, err := configureClient(ctx, providerConfig{
+		endpoint:      conn.Address,
+		apiKeyID:      conn.APIKeyID,
+		apiKeySecret:  conn.APIKeySecret,
+		email:         conn.Email,
+		token:         conn.Token,
+		tokenFile:     conn.TokenFile,
+		tokenCommand:  conn.TokenCommand,
+		tokenCacheDir: d.Get("token_cache_dir").(string),
+		logLevel:      d.Get("log_level").(string),
+		skipPreflight: d.Get("skip_preflight").(bool),
+
+		requestsPerSecond:     d.Get("requests_per_second").(float64),
+		maxConcurrentRequests: d.Get("max_concurrent_requests").(int),
+		cacheReads:            d.Get("cache_reads").(bool),
 
+		allowedImageRegistries: expandStrings(d.Get("allowed_image_registries").([]interface{})),
+		deniedImagePatterns:    expandStrings(d.Get("denied_image_patterns").([]interface{})),
+		requireDigest:          d.Get("require_digest").(bool),
+	})
+	if err != nil {
+		return nil, configureDiagnostics(err)
+	}
 	return client, nil
 }
 
+// configureDiagnostics points a failed provider configuration at the argument which is most likely wrong.
+func configureDiagnostics(err error) diag.Diagnostics {
+	summary, attribute, ok := configureFailure(err)
+	if !ok {
+		return diag.FromErr(err)
+	}
+	return diag.Diagnostics{{
+		Severity:      diag.Error,
+		Summary:       summary,
+		Detail:        err.Error(),
+		AttributePath: cty.GetAttrPath(attribute),
+	}}
+}
+
+// configureFailure returns a summary and the provider argument which is most likely wrong for a failed
+// provider configuration. ok is false if err can't be attributed to an argument.
+func configureFailure(err error) (summary, attribute string, ok bool) {
+	if errors.Is(err, pkg.ErrInvalidAllowedRegistry) {
+		return "Invalid image policy", "allowed_image_registries", true
+	}
+	if errors.Is(err, pkg.ErrInvalidDeniedPattern) {
+		return "Invalid image policy", "denied_image_patterns", true
+	}
+	if errors.Is(err, errAPIKeySecretFile) {
+		return "Invalid API key secret", "api_key_secret_file", true
+	}
+	if errors.Is(err, errInvalidProfile) {
+		return "Invalid profile", "profile", true
+	}
+	if errors.Is(err, errInvalidAuth) {
+		return "Invalid authentication", "token", true
+	}
+	var preflightErr *pkg.PreflightError
+	if !errors.As(err, &preflightErr) {
+		return "", "", false
+	}
+	attribute = "endpoint"
+	if preflightErr.Reason == pkg.PreflightCredentials {
+		attribute = "api_key_secret"
+	}
+	return fmt.Sprintf("Krok %s", preflightErr.Reason), attribute, true
+}
+
+// expandStrings converts a list of strings from the Terraform store.
+func expandStrings(s []interface{}) []string {
+	var result []string
+	for _, v := range s {
+		result = append(result, v.(string))
+	}
+	return result
+}
+
 // counter is keeping track of the generated resources in an atomic way.
 // This will result in unique ids even with multiple terraform calls.
 var counter uint64
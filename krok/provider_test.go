@@ -1 +1,228 @@
 package krok
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+)
+
+func TestProvider(t *testing.T) {
+	if err := Provider().InternalValidate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProviderServer(t *testing.T) {
+	ctx := context.Background()
+	server, err := ProviderServer(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := server().GetProviderSchema(ctx, &tfprotov6.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the muxed providers have to agree on the provider schema.
+	for _, d := range resp.Diagnostics {
+		t.Errorf("%s: %s", d.Summary, d.Detail)
+	}
+	for _, r := range []string{
+		"krok_repository", "krok_command", "krok_platform", "krok_command_setting", "krok_command_archive",
+		"krok_command_repository_attachment", "krok_command_platform_attachment", "krok_command_settings",
+	} {
+		if _, ok := resp.ResourceSchemas[r]; !ok {
+			t.Errorf("resource %s is not served", r)
+		}
+	}
+	for _, d := range []string{"krok_command", "krok_platform", "krok_platforms"} {
+		if _, ok := resp.DataSourceSchemas[d]; !ok {
+			t.Errorf("data source %s is not served", d)
+		}
+	}
+}
+
+// setConnectionEnv clears the environment which resolveConnection reads and sets env instead.
+func setConnectionEnv(t *testing.T, env map[string]string) {
+	for _, k := range []string{
+		"KROK_ENDPOINT", "KROK_API_KEY_ID", "KROK_API_KEY_SECRET", "KROK_API_KEY_SECRET_FILE", "KROK_EMAIL",
+		"KROK_PROFILE", "KROK_CONFIG_FILE", "KROK_TOKEN", "KROK_TOKEN_FILE", "KROK_TOKEN_COMMAND",
+	} {
+		t.Setenv(k, env[k])
+	}
+}
+
+func TestResolveConnection(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	if err := os.WriteFile(config, []byte(`
+# profiles for tests
+[staging]
+endpoint       = https://staging.example.com
+email          = staging@example.com
+api_key_id     = staging-id
+api_key_secret = staging-secret
+
+[production]
+endpoint = https://production.example.com
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	secretFile := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secretFile, []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	environment := map[string]string{
+		"KROK_ENDPOINT":       "https://env.example.com",
+		"KROK_API_KEY_ID":     "env-id",
+		"KROK_API_KEY_SECRET": "env-secret",
+		"KROK_EMAIL":          "env@example.com",
+		"KROK_CONFIG_FILE":    config,
+	}
+	with := func(extra map[string]string) map[string]string {
+		env := make(map[string]string)
+		for k, v := range environment {
+			env[k] = v
+		}
+		for k, v := range extra {
+			env[k] = v
+		}
+		return env
+	}
+
+	for _, tc := range []struct {
+		name string
+		env  map[string]string
+		args connectionArguments
+		want pkg.Config
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{},
+			want: pkg.Config{Address: "http://localhost:9998"},
+		},
+		{
+			name: "environment",
+			env:  environment,
+			want: pkg.Config{Address: "https://env.example.com", APIKeyID: "env-id", APIKeySecret: "env-secret", Email: "env@example.com"},
+		},
+		{
+			name: "profile over environment",
+			env:  environment,
+			args: connectionArguments{profile: "staging"},
+			want: pkg.Config{Address: "https://staging.example.com", APIKeyID: "staging-id", APIKeySecret: "staging-secret", Email: "staging@example.com"},
+		},
+		{
+			name: "profile from environment",
+			env:  with(map[string]string{"KROK_PROFILE": "staging"}),
+			want: pkg.Config{Address: "https://staging.example.com", APIKeyID: "staging-id", APIKeySecret: "staging-secret", Email: "staging@example.com"},
+		},
+		{
+			name: "profile argument over KROK_PROFILE",
+			env:  with(map[string]string{"KROK_PROFILE": "staging"}),
+			args: connectionArguments{profile: "production"},
+			want: pkg.Config{Address: "https://production.example.com", APIKeyID: "env-id", APIKeySecret: "env-secret", Email: "env@example.com"},
+		},
+		{
+			name: "arguments over profile",
+			env:  environment,
+			args: connectionArguments{profile: "staging", endpoint: "https://arg.example.com", apiKeySecretFile: secretFile},
+			want: pkg.Config{Address: "https://arg.example.com", APIKeyID: "staging-id", APIKeySecret: "file-secret", Email: "staging@example.com"},
+		},
+		{
+			name: "secret file from environment over secret",
+			env:  with(map[string]string{"KROK_API_KEY_SECRET_FILE": secretFile}),
+			want: pkg.Config{Address: "https://env.example.com", APIKeyID: "env-id", APIKeySecret: "file-secret", Email: "env@example.com"},
+		},
+		{
+			name: "token argument over environment secret",
+			env:  environment,
+			args: connectionArguments{token: "arg-token"},
+			want: pkg.Config{Address: "https://env.example.com", APIKeyID: "env-id", Email: "env@example.com", Token: "arg-token"},
+		},
+		{
+			name: "secret argument over environment token",
+			env:  with(map[string]string{"KROK_TOKEN": "env-token"}),
+			args: connectionArguments{apiKeySecret: "arg-secret"},
+			want: pkg.Config{Address: "https://env.example.com", APIKeyID: "env-id", APIKeySecret: "arg-secret", Email: "env@example.com"},
+		},
+		{
+			name: "profile secret over environment token command",
+			env:  with(map[string]string{"KROK_TOKEN_COMMAND": "echo token"}),
+			args: connectionArguments{profile: "staging"},
+			want: pkg.Config{Address: "https://staging.example.com", APIKeyID: "staging-id", APIKeySecret: "staging-secret", Email: "staging@example.com"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			setConnectionEnv(t, tc.env)
+			got, err := resolveConnection(tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		name string
+		args connectionArguments
+		want error
+	}{
+		{name: "secret and secret file", args: connectionArguments{apiKeySecret: "secret", apiKeySecretFile: secretFile}, want: errAPIKeySecretFile},
+		{name: "missing secret file", args: connectionArguments{apiKeySecretFile: filepath.Join(dir, "missing")}, want: errAPIKeySecretFile},
+		{name: "unknown profile", args: connectionArguments{profile: "missing"}, want: errInvalidProfile},
+		{name: "secret and token", args: connectionArguments{apiKeySecret: "secret", token: "token"}, want: errInvalidAuth},
+		{name: "token file and token command", args: connectionArguments{tokenFile: secretFile, tokenCommand: "echo token"}, want: errInvalidAuth},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			setConnectionEnv(t, environment)
+			if _, err := resolveConnection(tc.args); !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestConfigureFailureImagePolicy(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  providerConfig
+		want string
+	}{
+		{cfg: providerConfig{allowedImageRegistries: []string{"https://registry.example.com"}}, want: "allowed_image_registries"},
+		{cfg: providerConfig{deniedImagePatterns: []string{"("}}, want: "denied_image_patterns"},
+	} {
+		_, err := configureClient(context.Background(), tc.cfg)
+		if _, attribute, ok := configureFailure(err); !ok || attribute != tc.want {
+			t.Errorf("expected %v to be attributed to %s, got %s", err, tc.want, attribute)
+		}
+	}
+}
+
+func TestProviderConfigKey(t *testing.T) {
+	empty := providerConfig{endpoint: "http://localhost:9998", allowedImageRegistries: []string{}, deniedImagePatterns: []string{}}
+	unset := providerConfig{endpoint: "http://localhost:9998"}
+	if empty.key() != unset.key() {
+		t.Errorf("empty and unset lists should share a key, got %s and %s", empty.key(), unset.key())
+	}
+	for _, other := range []providerConfig{
+		{endpoint: "http://localhost:9998", apiKeySecret: "secret"},
+		{endpoint: "http://localhost:9998", deniedImagePatterns: []string{""}},
+		{endpoint: "http://localhost:9998", allowedImageRegistries: []string{"a", "b"}},
+		{endpoint: "http://localhost:9998", allowedImageRegistries: []string{"a,b"}},
+		{endpoint: "http://localhost:9998", requestsPerSecond: 0.5},
+	} {
+		if other.key() == unset.key() {
+			t.Errorf("%+v should have its own key", other)
+		}
+	}
+	if (providerConfig{allowedImageRegistries: []string{"a", "b"}}).key() == (providerConfig{allowedImageRegistries: []string{"a,b"}}).key() {
+		t.Error("list entries should not run into each other")
+	}
+}
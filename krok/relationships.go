@@ -0,0 +1,127 @@
+package krok
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// relationshipWorkers is the number of relationship changes which are made at the same time.
+const relationshipWorkers = 8
+
+// relationshipRollbackTimeout limits a rollback, which runs even if the operation's context is done.
+const relationshipRollbackTimeout = time.Minute
+
+// relationshipChange attaches or detaches a command.
+type relationshipChange struct {
+	command int
+	// target is the ID of the repository or platform the command is attached to or detached from.
+	target int
+	// attach is false if the command is detached.
+	attach bool
+	// summary describes the change for diagnostics, like "attach command 3 to repository 5".
+	summary string
+	apply   func() error
+	// revert undoes apply.
+	revert func() error
+}
+
+// relationshipFailure is a change which couldn't be applied, or reverted if reverting is set.
+type relationshipFailure struct {
+	change    relationshipChange
+	reverting bool
+	err       error
+}
+
+func (f relationshipFailure) Error() string {
+	if f.reverting {
+		return fmt.Sprintf("failed to roll back the change to %s: %s", f.change.summary, f.err)
+	}
+	return fmt.Sprintf("failed to %s: %s", f.change.summary, f.err)
+}
+
+// commandRelationship returns the change attaching a command to target, or detaching it from target,
+// with the given client calls. The change is applied with ctx. It's reverted without ctx's deadline or
+// cancellation, since a change most likely fails because the operation ran out of time, but limited
+// by relationshipRollbackTimeout.
+func commandRelationship(ctx context.Context, command, target int, kind string, attach bool, add, remove func(context.Context, int, int) error) relationshipChange {
+	do, undo := add, remove
+	if !attach {
+		do, undo = remove, add
+	}
+	c := relationshipChange{
+		command: command,
+		target:  target,
+		attach:  attach,
+		apply:   func() error { return do(ctx, command, target) },
+		revert: func() error {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), relationshipRollbackTimeout)
+			defer cancel()
+			return undo(ctx, command, target)
+		},
+	}
+	if attach {
+		c.summary = fmt.Sprintf("attach command %d to %s %d", command, kind, target)
+	} else {
+		c.summary = fmt.Sprintf("detach command %d from %s %d", command, kind, target)
+	}
+	return c
+}
+
+// applyRelationshipChanges applies changes concurrently. All of them are tried, and if any fails, the
+// ones which have been applied are reverted, so the relationships are left as they were. It returns
+// every change which failed, followed by the ones which couldn't be reverted.
+func applyRelationshipChanges(changes []relationshipChange) []relationshipFailure {
+	applied := make([]bool, len(changes))
+	failed := runRelationshipChanges(changes, func(i int, c relationshipChange) error {
+		if err := c.apply(); err != nil {
+			return err
+		}
+		applied[i] = true
+		return nil
+	})
+	if len(failed) == 0 {
+		return nil
+	}
+	var revert []relationshipChange
+	for i, c := range changes {
+		if applied[i] {
+			revert = append(revert, c)
+		}
+	}
+	notReverted := runRelationshipChanges(revert, func(_ int, c relationshipChange) error {
+		return c.revert()
+	})
+	for i := range notReverted {
+		notReverted[i].reverting = true
+	}
+	return append(failed, notReverted...)
+}
+
+// runRelationshipChanges calls run for every change, with at most relationshipWorkers at a time, and returns
+// the failures in the order of changes.
+func runRelationshipChanges(changes []relationshipChange, run func(int, relationshipChange) error) []relationshipFailure {
+	errs := make([]error, len(changes))
+	workers := make(chan struct{}, relationshipWorkers)
+	var wg sync.WaitGroup
+	for i, c := range changes {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			errs[i] = run(i, c)
+		}()
+	}
+	wg.Wait()
+	var failures []relationshipFailure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, relationshipFailure{change: changes[i], err: err})
+		}
+	}
+	return failures
+}
@@ -0,0 +1,161 @@
+package krok
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRelationships records the commands attached to a repository.
+type fakeRelationships struct {
+	lock     sync.Mutex
+	attached map[int]bool
+	// failAttach and failDetach are the commands which can't be attached or detached.
+	failAttach, failDetach map[int]bool
+
+	inFlight, maxInFlight atomic.Int32
+}
+
+func (f *fakeRelationships) call(command int, attach bool) error {
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		m := f.maxInFlight.Load()
+		if n <= m || f.maxInFlight.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if (attach && f.failAttach[command]) || (!attach && f.failDetach[command]) {
+		return errors.New("return code was not OK 500")
+	}
+	f.attached[command] = attach
+	return nil
+}
+
+func (f *fakeRelationships) changes(attach, detach []int) []relationshipChange {
+	add := func(_ context.Context, command, _ int) error { return f.call(command, true) }
+	remove := func(_ context.Context, command, _ int) error { return f.call(command, false) }
+	var changes []relationshipChange
+	for _, c := range attach {
+		changes = append(changes, commandRelationship(context.Background(), c, 1, "repository", true, add, remove))
+	}
+	for _, c := range detach {
+		changes = append(changes, commandRelationship(context.Background(), c, 1, "repository", false, add, remove))
+	}
+	return changes
+}
+
+func (f *fakeRelationships) attachedCommands() string {
+	var commands []string
+	for c := 0; c < 100; c++ {
+		if f.attached[c] {
+			commands = append(commands, fmt.Sprint(c))
+		}
+	}
+	return strings.Join(commands, ",")
+}
+
+func TestApplyRelationshipChanges(t *testing.T) {
+	f := &fakeRelationships{attached: map[int]bool{50: true, 51: true}}
+	var attach []int
+	for c := 0; c < 40; c++ {
+		attach = append(attach, c)
+	}
+	if failures := applyRelationshipChanges(f.changes(attach, []int{50})); len(failures) != 0 {
+		t.Fatalf("expected no failures, got %v", failures)
+	}
+	if got := f.attachedCommands(); got != "0,1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20,21,22,23,24,25,26,27,28,29,30,31,32,33,34,35,36,37,38,39,51" {
+		t.Fatalf("unexpected commands attached: %s", got)
+	}
+	if m := f.maxInFlight.Load(); m > relationshipWorkers || m < 2 {
+		t.Fatalf("expected changes to run concurrently with at most %d at a time, got %d", relationshipWorkers, m)
+	}
+}
+
+func TestApplyRelationshipChangesRollsBack(t *testing.T) {
+	f := &fakeRelationships{
+		attached:   map[int]bool{50: true, 51: true},
+		failAttach: map[int]bool{3: true, 7: true},
+	}
+	failures := applyRelationshipChanges(f.changes([]int{1, 2, 3, 4, 5, 6, 7}, []int{50}))
+	var got []string
+	for _, f := range failures {
+		got = append(got, f.Error())
+	}
+	want := []string{
+		"failed to attach command 3 to repository 1: return code was not OK 500",
+		"failed to attach command 7 to repository 1: return code was not OK 500",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected every failure to be reported:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if got := f.attachedCommands(); got != "50,51" {
+		t.Fatalf("expected the applied changes to be rolled back, got commands %s", got)
+	}
+
+	// changes which can't be rolled back are reported too.
+	f = &fakeRelationships{
+		attached:   map[int]bool{},
+		failAttach: map[int]bool{2: true},
+		failDetach: map[int]bool{1: true},
+	}
+	failures = applyRelationshipChanges(f.changes([]int{1, 2}, nil))
+	if len(failures) != 2 || failures[0].reverting || !failures[1].reverting || failures[1].change.command != 1 {
+		t.Fatalf("expected the failed attach and the failed rollback, got %v", failures)
+	}
+	if got := failures[1].Error(); got != "failed to roll back the change to attach command 1 to repository 1: return code was not OK 500" {
+		t.Fatalf("unexpected error %q", got)
+	}
+}
+
+func TestApplyRelationshipChangesRollsBackAfterDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var (
+		lock     sync.Mutex
+		attached = map[int]bool{}
+		bounded  bool
+	)
+	add := func(ctx context.Context, command, _ int) error {
+		if command == 2 {
+			// the operation runs out of time while attaching command 2.
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		attached[command] = true
+		return nil
+	}
+	remove := func(ctx context.Context, command, _ int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		_, bounded = ctx.Deadline()
+		attached[command] = false
+		return nil
+	}
+	failures := applyRelationshipChanges([]relationshipChange{
+		commandRelationship(ctx, 1, 1, "repository", true, add, remove),
+		commandRelationship(ctx, 2, 1, "repository", true, add, remove),
+	})
+	if len(failures) != 1 || !errors.Is(failures[0].err, context.DeadlineExceeded) {
+		t.Fatalf("expected only attaching command 2 to fail with the deadline, got %v", failures)
+	}
+	if attached[1] {
+		t.Fatal("expected command 1 to be detached again after the deadline")
+	}
+	if !bounded {
+		t.Fatal("expected the rollback to have a deadline of its own")
+	}
+}
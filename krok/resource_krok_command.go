@@ -1,11 +1,17 @@
 package krok
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"slices"
 	"strconv"
+	"time"
 
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	"github.com/krok-o/krok/pkg/models"
 
@@ -19,37 +25,59 @@ const (
 	commandResourcePlatformsFieldName    = "platforms"
 	commandResourceRepositoriesFieldName = "repositories"
 	commandResourceEnabledFieldName      = "enabled"
-	// repoEventsFieldName                      = "name"
+	commandResourceNextRunTimeFieldName  = "next_run_time"
 )
 
 func resourceCommand() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceCommandCreate,
-		Read:   resourceCommandRead,
-		Update: resourceCommandUpdate,
-		Delete: resourceCommandDelete,
+		CreateContext: resourceCommandCreate,
+		ReadContext:   resourceCommandRead,
+		UpdateContext: resourceCommandUpdate,
+		DeleteContext: resourceCommandDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceCommandImport,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(defaultCreateTimeout),
+			Read:   schema.DefaultTimeout(defaultReadTimeout),
+			Update: schema.DefaultTimeout(defaultUpdateTimeout),
+			Delete: schema.DefaultTimeout(defaultDeleteTimeout),
+		},
+		CustomizeDiff: customdiff.All(
+			customizeCommandNextRunTime,
+			customizeCommandUniqueName,
+			customizeCommandImagePolicy,
+		),
 
 		Schema: map[string]*schema.Schema{
 			commandResourceNameFieldName: {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validation.StringIsNotEmpty),
 			},
 			commandResourceImageFieldName: {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validateImage),
 			},
 			commandResourceScheduleFieldName: {
-				Type:     schema.TypeString,
-				Optional: true,
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateDiagFunc: validation.ToDiagFunc(validateSchedule),
+			},
+			commandResourceNextRunTimeFieldName: {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The next time the command runs according to its schedule, in RFC 3339 format. It's computed when the schedule changes and isn't updated on refresh.",
 			},
 			commandResourceEnabledFieldName: {
 				Type:     schema.TypeBool,
 				Required: true,
 			},
-			// TODO: Add support for updating this field.
 			commandResourcePlatformsFieldName: {
-				Type:     schema.TypeList,
-				Required: true,
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "IDs of the platforms the command runs for. Leave it unset to attach platforms with krok_command_platform_attachment instead.",
 				Elem: &schema.Schema{
 					Type: schema.TypeInt,
 				},
@@ -65,55 +93,103 @@ func resourceCommand() *schema.Resource {
 	}
 }
 
+// customizeCommandNextRunTime computes the next run time when the schedule changes. It isn't updated
+// on refresh, so the state settles until the schedule changes again.
+func customizeCommandNextRunTime(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if !d.HasChange(commandResourceScheduleFieldName) {
+		return nil
+	}
+	if !d.NewValueKnown(commandResourceScheduleFieldName) {
+		return d.SetNewComputed(commandResourceNextRunTimeFieldName)
+	}
+	return d.SetNew(commandResourceNextRunTimeFieldName, nextRunTime(d.Get(commandResourceScheduleFieldName).(string), time.Now()))
+}
+
+// customizeCommandUniqueName rejects names which are already taken by another command, which Krok refuses.
+func customizeCommandUniqueName(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if !d.HasChange(commandResourceNameFieldName) || !d.NewValueKnown(commandResourceNameFieldName) {
+		return nil
+	}
+	client, ok := m.(*pkg.KrokClient)
+	if !ok {
+		return nil
+	}
+	commands, err := client.CommandClient.List(ctx, &models.ListOptions{})
+	if err != nil {
+		// don't fail the plan, Krok checks this again when the command is saved.
+		client.Logger.Debug().Err(err).Msg("Failed to list commands to check the name.")
+		return nil
+	}
+	name := d.Get(commandResourceNameFieldName).(string)
+	for _, c := range commands {
+		if c.Name == name && strconv.Itoa(c.ID) != d.Id() {
+			return fmt.Errorf("a command with the name %q already exists with id %d", name, c.ID)
+		}
+	}
+	return nil
+}
+
+// customizeCommandImagePolicy rejects images which violate the image policy of the provider.
+func customizeCommandImagePolicy(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	client, ok := m.(*pkg.KrokClient)
+	if !ok || !d.NewValueKnown(commandResourceImageFieldName) {
+		return nil
+	}
+	return client.ImagePolicy.Check(d.Get(commandResourceImageFieldName).(string))
+}
+
 // resourceCommandCreate creates a Krok repository.
-func resourceCommandCreate(d *schema.ResourceData, m interface{}) error {
+func resourceCommandCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*pkg.KrokClient)
-	expandedCommand, err := expandCommandResource(d)
-	if err != nil {
-		return err
+	expandedCommand, diags := expandCommandResource(d)
+	if diags.HasError() {
+		return diags
 	}
-	createdCommand, err := client.CommandClient.Create(expandedCommand)
+	createdCommand, err := client.CommandClient.Create(ctx, expandedCommand)
 	if err != nil {
-		log.Println("Failed to create command.")
-		return fmt.Errorf("failed to create command: %w", err)
+		client.Logger.Debug().Err(err).Msg("Failed to create command.")
+		return diag.FromErr(fmt.Errorf("failed to create command: %w", err))
 	}
+	// from here on the command exists. If a later step fails, only its ID is stored and Terraform marks
+	// it as tainted, so the next apply replaces it instead of creating a duplicate.
+	d.SetId(strconv.Itoa(createdCommand.ID))
+	d.Partial(true)
 
 	// add any relationships that might exist for commands.
 	if v, ok := d.GetOk(commandResourcePlatformsFieldName); ok {
-		providers := v.([]interface{})
-		for _, pid := range providers {
-			if err := client.CommandClient.AddRelationshipToPlatform(createdCommand.ID, pid.(int)); err != nil {
-				log.Println("Failed to create relationship for command and platform.")
-				return fmt.Errorf("failed to add relationship between command %d and platform %d: %w", createdCommand.ID, pid, err)
-			}
+		var changes []relationshipChange
+		for _, pid := range v.([]interface{}) {
+			changes = append(changes, platformChange(ctx, client, createdCommand.ID, pid.(int), true))
+		}
+		if diags := applyPlatformChanges(client, changes, v.([]interface{})); diags.HasError() {
+			return diags
 		}
 	}
-	d.SetId(strconv.Itoa(createdCommand.ID))
-	return resourceCommandRead(d, m)
+	d.Partial(false)
+	if diags := resourceCommandRead(ctx, d, m); diags.HasError() {
+		// the command exists even if it can't be read back.
+		d.SetId(strconv.Itoa(createdCommand.ID))
+		return diags
+	}
+	return nil
 }
 
 // expandCommandResource creates a Krok command structure out of a Terraform schema model.
-func expandCommandResource(d *schema.ResourceData) (*models.Command, error) {
+func expandCommandResource(d *schema.ResourceData) (*models.Command, diag.Diagnostics) {
 	var (
 		name     string
 		image    string
 		schedule string
-		enabled  bool
 	)
 	if v, ok := d.GetOk(commandResourceNameFieldName); ok {
 		name = v.(string)
 	} else {
-		return nil, fmt.Errorf("unable to find or parse field %s", commandResourceNameFieldName)
+		return nil, missingFieldDiag(commandResourceNameFieldName)
 	}
 	if v, ok := d.GetOk(commandResourceImageFieldName); ok {
 		image = v.(string)
 	} else {
-		return nil, fmt.Errorf("unable to find or parse field %s", commandResourceImageFieldName)
-	}
-	if v, ok := d.GetOk(commandResourceEnabledFieldName); ok {
-		enabled = v.(bool)
-	} else {
-		return nil, fmt.Errorf("unable to find or parse field %s", commandResourceEnabledFieldName)
+		return nil, missingFieldDiag(commandResourceImageFieldName)
 	}
 	if v, ok := d.GetOk(commandResourceScheduleFieldName); ok {
 		schedule = v.(string)
@@ -122,29 +198,60 @@ func expandCommandResource(d *schema.ResourceData) (*models.Command, error) {
 		Name:     name,
 		Image:    image,
 		Schedule: schedule,
-		Enabled:  enabled,
+		// GetOk can't tell false from unset, but the field is required.
+		Enabled: d.Get(commandResourceEnabledFieldName).(bool),
 	}
 	return command, nil
 }
 
+// resourceCommandImport imports a command by its ID. The platforms of an imported command are managed
+// by it, so they are stored right away, which the read after the import then keeps up to date.
+func resourceCommandImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*pkg.KrokClient)
+	cid, err := strconv.Atoi(d.Id())
+	if err != nil {
+		return nil, fmt.Errorf("invalid command ID %q: %w", d.Id(), err)
+	}
+	command, err := client.CommandClient.Get(ctx, cid)
+	if err != nil {
+		client.Logger.Debug().Err(err).Msg("Failed to find command")
+		return nil, err
+	}
+	if err := d.Set(commandResourcePlatformsFieldName, flattenCommand(command)[commandResourcePlatformsFieldName]); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
 // resourceCommandRead retrieves command information from terraform stores.
-func resourceCommandRead(d *schema.ResourceData, m interface{}) error {
+func resourceCommandRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*pkg.KrokClient)
 	cid, err := strconv.Atoi(d.Id())
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	command, err := client.CommandClient.Get(cid)
+	command, err := client.CommandClient.Get(ctx, cid)
 	if err != nil {
-		log.Println("Failed to find command")
+		client.Logger.Debug().Err(err).Msg("Failed to find command")
 		d.SetId("")
-		return err
+		return diag.FromErr(err)
 	}
 
+	// the next run time is computed during plan, unless the schedule wasn't known yet or the command was imported.
+	if d.Get(commandResourceNextRunTimeFieldName).(string) == "" {
+		if err := d.Set(commandResourceNextRunTimeFieldName, nextRunTime(command.Schedule, time.Now())); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	managed := len(d.Get(commandResourcePlatformsFieldName).([]interface{})) > 0
 	for k, v := range flattenCommand(command) {
+		// without configured platforms the relationships are left to krok_command_platform_attachment.
+		if k == commandResourcePlatformsFieldName && !managed && !d.IsNewResource() {
+			continue
+		}
 		if err := d.Set(k, v); err != nil {
 			d.SetId("")
-			return err
+			return diag.FromErr(err)
 		}
 	}
 	return nil
@@ -174,17 +281,17 @@ func flattenCommand(command *models.Command) map[string]interface{} {
 }
 
 // resourceCommandUpdate checks fields for differences and updates a command if necessary.
-func resourceCommandUpdate(d *schema.ResourceData, m interface{}) error {
+func resourceCommandUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*pkg.KrokClient)
 	cid, err := strconv.Atoi(d.Id())
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	command, err := client.CommandClient.Get(cid)
+	command, err := client.CommandClient.Get(ctx, cid)
 	if err != nil {
-		log.Println("Failed to find command")
+		client.Logger.Debug().Err(err).Msg("Failed to find command")
 		d.SetId("")
-		return err
+		return diag.FromErr(err)
 	}
 
 	if d.HasChange(commandResourceNameFieldName) {
@@ -195,23 +302,93 @@ func resourceCommandUpdate(d *schema.ResourceData, m interface{}) error {
 		command.Schedule = d.Get(commandResourceScheduleFieldName).(string)
 	}
 
-	if res, err := client.CommandClient.Update(command); err != nil {
-		log.Println("Failed to update command")
-		return fmt.Errorf("failed to update command: %w", err)
+	if d.HasChange(commandResourcePlatformsFieldName) {
+		if diags := updateCommandPlatforms(ctx, d, client, command); diags.HasError() {
+			return diags
+		}
+	}
+
+	if res, err := client.CommandClient.Update(ctx, command); err != nil {
+		client.Logger.Debug().Err(err).Msg("Failed to update command")
+		return diag.FromErr(fmt.Errorf("failed to update command: %w", err))
 	} else {
 		d.SetId(strconv.Itoa(res.ID))
 	}
-	return resourceCommandRead(d, m)
+	return resourceCommandRead(ctx, d, m)
+}
+
+// updateCommandPlatforms attaches added and detaches removed platforms. Platforms which are attached
+// outside of the command, for example by krok_command_platform_attachment, are left alone.
+func updateCommandPlatforms(ctx context.Context, d *schema.ResourceData, client *pkg.KrokClient, command *models.Command) diag.Diagnostics {
+	cid := command.ID
+	o, n := d.GetChange(commandResourcePlatformsFieldName)
+	old := make(map[int]bool)
+	for _, pid := range o.([]interface{}) {
+		old[pid.(int)] = true
+	}
+	attached := make(map[int]bool, len(command.Platforms))
+	for _, p := range command.Platforms {
+		attached[p.ID] = true
+	}
+	wanted := make(map[int]bool)
+	var changes []relationshipChange
+	for _, pid := range n.([]interface{}) {
+		wanted[pid.(int)] = true
+		if !attached[pid.(int)] {
+			changes = append(changes, platformChange(ctx, client, cid, pid.(int), true))
+		}
+	}
+	for pid := range old {
+		if !wanted[pid] && attached[pid] {
+			changes = append(changes, platformChange(ctx, client, cid, pid, false))
+		}
+	}
+	return applyPlatformChanges(client, changes, n.([]interface{}))
+}
+
+// platformChange returns the change attaching the command to the platform, or detaching it.
+func platformChange(ctx context.Context, client *pkg.KrokClient, commandID, platformID int, attach bool) relationshipChange {
+	return commandRelationship(ctx, commandID, platformID, "platform", attach,
+		client.CommandClient.AddRelationshipToPlatform,
+		client.CommandClient.RemoveRelationshipToPlatform,
+	)
+}
+
+// applyPlatformChanges applies changes to the platforms of a command, all of them or none. Failures to
+// attach a platform point at its index in platforms.
+func applyPlatformChanges(client *pkg.KrokClient, changes []relationshipChange, platforms []interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, f := range applyRelationshipChanges(changes) {
+		client.Logger.Debug().Err(f.err).Msg(f.Error())
+		i := slices.Index(platforms, interface{}(f.change.target))
+		switch {
+		case f.reverting:
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "failed to roll back platform relationship",
+				Detail:   f.Error() + ". The platforms of the command are partially changed, the next plan shows what's left to do.",
+			})
+		case f.change.attach && i >= 0:
+			diags = append(diags, attributeDiag(
+				fmt.Sprintf("failed to add relationship between command %d and platform %d", f.change.command, f.change.target),
+				f.err,
+				cty.GetAttrPath(commandResourcePlatformsFieldName).IndexInt(i),
+			))
+		default:
+			diags = append(diags, diag.FromErr(f)...)
+		}
+	}
+	return diags
 }
 
-func resourceCommandDelete(d *schema.ResourceData, m interface{}) error {
+func resourceCommandDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*pkg.KrokClient)
 	cid, err := strconv.Atoi(d.Id())
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	if err := client.CommandClient.Delete(cid); err != nil {
-		return err
+	if err := client.CommandClient.Delete(ctx, cid); err != nil {
+		return diag.FromErr(err)
 	}
 	d.SetId("") // called automatically, but added to be explicit
 	return nil
@@ -0,0 +1,218 @@
+package krok
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+	"github.com/krok-o/terraform-provider-krok/pkg/archive"
+)
+
+const (
+	commandArchiveNameFieldName       = "name"
+	commandArchiveSourceFieldName     = "source"
+	commandArchiveSourceHashFieldName = "source_hash"
+)
+
+var (
+	_ resource.ResourceWithConfigure  = &commandArchiveResource{}
+	_ resource.ResourceWithModifyPlan = &commandArchiveResource{}
+)
+
+// commandArchiveResource creates a command by uploading a local directory or file as command archive.
+type commandArchiveResource struct {
+	client *pkg.KrokClient
+}
+
+// commandArchiveResourceModel maps the krok_command_archive schema.
+type commandArchiveResourceModel struct {
+	ID         types.String   `tfsdk:"id"`
+	Name       types.String   `tfsdk:"name"`
+	Source     types.String   `tfsdk:"source"`
+	SourceHash types.String   `tfsdk:"source_hash"`
+	Timeouts   *timeoutsModel `tfsdk:"timeouts"`
+}
+
+// NewCommandArchiveResource creates the krok_command_archive resource.
+func NewCommandArchiveResource() resource.Resource {
+	return &commandArchiveResource{}
+}
+
+func (r *commandArchiveResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_command_archive"
+}
+
+func (r *commandArchiveResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Creates a command out of a local directory or file, which is packaged as gzipped tar archive and uploaded to Krok.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			commandArchiveNameFieldName: schema.StringAttribute{
+				Description: "Name of the command.",
+				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			commandArchiveSourceFieldName: schema.StringAttribute{
+				Description: "Path to the directory or file which is uploaded. The contents of a directory end up at the root of the archive.",
+				Required:    true,
+			},
+			commandArchiveSourceHashFieldName: schema.StringAttribute{
+				Description: "SHA-256 sum of the uncompressed archive. The command is replaced whenever it changes.",
+				Computed:    true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			timeoutsFieldName: timeoutsBlock(defaultUploadTimeout, defaultReadTimeout, defaultUpdateTimeout, defaultDeleteTimeout),
+		},
+	}
+}
+
+func (r *commandArchiveResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configuredClient(req.ProviderData, &resp.Diagnostics)
+}
+
+// ModifyPlan hashes the source, so a changed source shows up as a change of source_hash, which replaces
+// the command. It fails the plan if the server can't take the upload.
+func (r *commandArchiveResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+	if r.client != nil && !r.client.Capabilities.Supports(pkg.FeatureCommandUpload) {
+		resp.Diagnostics.AddError("Command uploads are not supported",
+			"The Krok server doesn't accept command archives, so krok_command_archive can't create the command. Use krok_command with an image instead.")
+		return
+	}
+	var source types.String
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root(commandArchiveSourceFieldName), &source)...)
+	if resp.Diagnostics.HasError() || source.IsUnknown() {
+		return
+	}
+	hash, err := archive.Hash(source.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root(commandArchiveSourceFieldName), "Failed to package source", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root(commandArchiveSourceHashFieldName), hash)...)
+	if req.State.Raw.IsNull() {
+		return
+	}
+	var previous types.String
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root(commandArchiveSourceHashFieldName), &previous)...)
+	if previous.ValueString() != hash {
+		resp.RequiresReplace = append(resp.RequiresReplace, path.Root(commandArchiveSourceHashFieldName))
+	}
+}
+
+// Create uploads the archive, which creates the command.
+func (r *commandArchiveResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan commandArchiveResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, plan.Timeouts.create(defaultUploadTimeout))
+	defer cancel()
+	if err := r.upload(ctx, &plan); err != nil {
+		resp.Diagnostics.AddError("Failed to upload command archive", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+// upload packages and streams the source to Krok and records the ID of the command.
+func (r *commandArchiveResource) upload(ctx context.Context, data *commandArchiveResourceModel) error {
+	source := data.Source.ValueString()
+	command, err := r.client.CommandClient.UploadArchive(ctx, data.Name.ValueString(), func(w io.Writer) error {
+		return archive.Write(w, source)
+	})
+	if err != nil {
+		r.client.Logger.Debug().Err(err).Str("source", source).Msg("Failed to upload command archive.")
+		return err
+	}
+	data.ID = types.StringValue(strconv.Itoa(command.ID))
+	if data.SourceHash.IsUnknown() {
+		hash, err := archive.Hash(source)
+		if err != nil {
+			return err
+		}
+		data.SourceHash = types.StringValue(hash)
+	}
+	return nil
+}
+
+// Read checks that the command still exists, and removes the resource if it doesn't.
+func (r *commandArchiveResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state commandArchiveResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	cid, err := strconv.Atoi(state.ID.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("id"), "Invalid command ID", err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, state.Timeouts.read(defaultReadTimeout))
+	defer cancel()
+	command, err := r.client.CommandClient.Get(ctx, cid)
+	if errors.Is(err, pkg.ErrNotFound) {
+		r.client.Logger.Debug().Str("id", state.ID.ValueString()).Msg("Command no longer exists.")
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		r.client.Logger.Debug().Err(err).Msg("Failed to find command")
+		resp.Diagnostics.AddError("Failed to find command", err.Error())
+		return
+	}
+	state.Name = types.StringValue(command.Name)
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+}
+
+// Update only stores the plan. A changed archive replaces the command instead, because uploading it
+// again would create a second command.
+func (r *commandArchiveResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan, state commandArchiveResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	plan.ID = state.ID
+	plan.SourceHash = state.SourceHash
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+func (r *commandArchiveResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state commandArchiveResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	cid, err := strconv.Atoi(state.ID.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("id"), "Invalid command ID", err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, state.Timeouts.delete(defaultDeleteTimeout))
+	defer cancel()
+	if err := r.client.CommandClient.Delete(ctx, cid); err != nil {
+		resp.Diagnostics.AddError("Failed to delete command", err.Error())
+	}
+}
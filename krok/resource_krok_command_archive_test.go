@@ -0,0 +1,84 @@
+package krok
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/krok/pkg/models"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+	"github.com/krok-o/terraform-provider-krok/pkg/clients/fake"
+)
+
+func TestCommandArchivePlanRequiresUploads(t *testing.T) {
+	ctx := context.Background()
+	source := filepath.Join(t.TempDir(), "main.sh")
+	if err := os.WriteFile(source, []byte("echo hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := &commandArchiveResource{client: pkg.NewKrokClient(pkg.Config{Address: "http://localhost"}, zerolog.Nop())}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	null := tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)
+	plan := tfsdk.Plan{Schema: schemaResp.Schema, Raw: null}
+	if diags := plan.Set(ctx, commandArchiveResourceModel{
+		ID:         types.StringUnknown(),
+		Name:       types.StringValue("notify"),
+		Source:     types.StringValue(source),
+		SourceHash: types.StringUnknown(),
+	}); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	resp := resource.ModifyPlanResponse{Plan: plan}
+	r.ModifyPlan(ctx, resource.ModifyPlanRequest{
+		Plan:  plan,
+		State: tfsdk.State{Schema: schemaResp.Schema, Raw: null},
+	}, &resp)
+	if !resp.Diagnostics.HasError() {
+		t.Fatal("expected the plan to fail, since Krok doesn't accept command archives")
+	}
+}
+
+func TestCommandArchiveReadRemovesDeletedCommand(t *testing.T) {
+	ctx := context.Background()
+	server := fake.NewServer()
+	defer server.Close()
+	server.AddCommand(&models.Command{ID: 1, Name: "notify"})
+	r := &commandArchiveResource{client: pkg.NewKrokClient(pkg.Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: fake.APIKeySecret,
+	}, zerolog.Nop())}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	for id, wantRemoved := range map[string]bool{"1": false, "2": true} {
+		state := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+		if diags := state.Set(ctx, commandArchiveResourceModel{
+			ID:         types.StringValue(id),
+			Name:       types.StringValue("notify"),
+			Source:     types.StringValue("."),
+			SourceHash: types.StringValue("hash"),
+		}); diags.HasError() {
+			t.Fatal(diags)
+		}
+		resp := resource.ReadResponse{State: state}
+		r.Read(ctx, resource.ReadRequest{State: state}, &resp)
+		for _, d := range resp.Diagnostics {
+			t.Fatalf("%s: %s", d.Summary(), d.Detail())
+		}
+		if removed := resp.State.Raw.IsNull(); removed != wantRemoved {
+			t.Errorf("command %s: expected removed %t, got %t", id, wantRemoved, removed)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package krok
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/krok-o/krok/pkg/models"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+)
+
+const attachmentCommandIDFieldName = "command_id"
+
+var (
+	_ resource.ResourceWithConfigure   = &commandAttachmentResource{}
+	_ resource.ResourceWithImportState = &commandAttachmentResource{}
+)
+
+// commandAttachmentResource manages a single relationship between a command and a repository or platform,
+// so commands and the things they are attached to can be owned by different configurations.
+type commandAttachmentResource struct {
+	client *pkg.KrokClient
+	// target is what the command is attached to, like repository.
+	target string
+	// attach and detach create and remove the relationship.
+	attach func(ctx context.Context, c *pkg.KrokClient, commandID, targetID int) error
+	detach func(ctx context.Context, c *pkg.KrokClient, commandID, targetID int) error
+	// attached returns the IDs the command is attached to.
+	attached func(command *models.Command) []int
+}
+
+// commandAttachmentResourceModel maps the schema of the attachment resources. TargetID is repository_id or platform_id.
+type commandAttachmentResourceModel struct {
+	ID        types.String   `tfsdk:"id"`
+	CommandID types.Int64    `tfsdk:"command_id"`
+	TargetID  types.Int64    `tfsdk:"-"`
+	Timeouts  *timeoutsModel `tfsdk:"timeouts"`
+}
+
+// NewCommandRepositoryAttachmentResource creates the krok_command_repository_attachment resource.
+func NewCommandRepositoryAttachmentResource() resource.Resource {
+	return &commandAttachmentResource{
+		target: "repository",
+		attach: func(ctx context.Context, c *pkg.KrokClient, commandID, targetID int) error {
+			return c.CommandClient.AddRelationshipToRepository(ctx, commandID, targetID)
+		},
+		detach: func(ctx context.Context, c *pkg.KrokClient, commandID, targetID int) error {
+			return c.CommandClient.RemoveRelationshipToRepository(ctx, commandID, targetID)
+		},
+		attached: func(command *models.Command) (ids []int) {
+			for _, r := range command.Repositories {
+				ids = append(ids, r.ID)
+			}
+			return ids
+		},
+	}
+}
+
+// NewCommandPlatformAttachmentResource creates the krok_command_platform_attachment resource.
+func NewCommandPlatformAttachmentResource() resource.Resource {
+	return &commandAttachmentResource{
+		target: "platform",
+		attach: func(ctx context.Context, c *pkg.KrokClient, commandID, targetID int) error {
+			return c.CommandClient.AddRelationshipToPlatform(ctx, commandID, targetID)
+		},
+		detach: func(ctx context.Context, c *pkg.KrokClient, commandID, targetID int) error {
+			return c.CommandClient.RemoveRelationshipToPlatform(ctx, commandID, targetID)
+		},
+		attached: func(command *models.Command) (ids []int) {
+			for _, p := range command.Platforms {
+				ids = append(ids, p.ID)
+			}
+			return ids
+		},
+	}
+}
+
+// targetIDFieldName is the name of the attribute holding the ID of the repository or platform.
+func (r *commandAttachmentResource) targetIDFieldName() string {
+	return r.target + "_id"
+}
+
+func (r *commandAttachmentResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_command_" + r.target + "_attachment"
+}
+
+func (r *commandAttachmentResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: fmt.Sprintf("Attaches a command to a %s. Don't manage the same relationship from krok_repository commands or krok_command platforms as well.", r.target),
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			attachmentCommandIDFieldName: schema.Int64Attribute{
+				Description: "ID of the command.",
+				Required:    true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+			r.targetIDFieldName(): schema.Int64Attribute{
+				Description: fmt.Sprintf("ID of the %s the command is attached to.", r.target),
+				Required:    true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+		},
+		Blocks: map[string]schema.Block{
+			timeoutsFieldName: timeoutsBlock(defaultCreateTimeout, defaultReadTimeout, defaultUpdateTimeout, defaultDeleteTimeout),
+		},
+	}
+}
+
+func (r *commandAttachmentResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configuredClient(req.ProviderData, &resp.Diagnostics)
+}
+
+// get reads the model from a plan or state. The target ID can't be mapped with a struct tag, because
+// the name of its attribute depends on the target.
+func (r *commandAttachmentResource) get(ctx context.Context, getAttribute func(context.Context, path.Path, interface{}) diag.Diagnostics) (data commandAttachmentResourceModel, diags diag.Diagnostics) {
+	diags.Append(getAttribute(ctx, path.Root("id"), &data.ID)...)
+	diags.Append(getAttribute(ctx, path.Root(attachmentCommandIDFieldName), &data.CommandID)...)
+	diags.Append(getAttribute(ctx, path.Root(r.targetIDFieldName()), &data.TargetID)...)
+	diags.Append(getAttribute(ctx, path.Root(timeoutsFieldName), &data.Timeouts)...)
+	return data, diags
+}
+
+// set stores the model in state.
+func (r *commandAttachmentResource) set(ctx context.Context, state *tfsdk.State, data commandAttachmentResourceModel) (diags diag.Diagnostics) {
+	diags.Append(state.SetAttribute(ctx, path.Root("id"), data.ID)...)
+	diags.Append(state.SetAttribute(ctx, path.Root(attachmentCommandIDFieldName), data.CommandID)...)
+	diags.Append(state.SetAttribute(ctx, path.Root(r.targetIDFieldName()), data.TargetID)...)
+	diags.Append(state.SetAttribute(ctx, path.Root(timeoutsFieldName), data.Timeouts)...)
+	return diags
+}
+
+// Create attaches the command.
+func (r *commandAttachmentResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	plan, diags := r.get(ctx, req.Plan.GetAttribute)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, plan.Timeouts.create(defaultCreateTimeout))
+	defer cancel()
+	commandID, targetID := int(plan.CommandID.ValueInt64()), int(plan.TargetID.ValueInt64())
+	if err := r.attach(ctx, r.client, commandID, targetID); err != nil {
+		r.client.Logger.Debug().Err(err).Msgf("Failed to create relationship for command and %s.", r.target)
+		resp.Diagnostics.AddError(
+			fmt.Sprintf("Failed to create relationship for command and %s", r.target),
+			fmt.Sprintf("failed to add relationship between command %d and %s %d: %s", commandID, r.target, targetID, err),
+		)
+		return
+	}
+	plan.ID = types.StringValue(attachmentID(commandID, targetID))
+	resp.Diagnostics.Append(r.set(ctx, &resp.State, plan)...)
+}
+
+// Read removes the attachment from state if the relationship or the command has been removed outside of Terraform.
+func (r *commandAttachmentResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	state, diags := r.get(ctx, req.State.GetAttribute)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, state.Timeouts.read(defaultReadTimeout))
+	defer cancel()
+	command, err := r.client.CommandClient.Get(ctx, int(state.CommandID.ValueInt64()))
+	if errors.Is(err, pkg.ErrNotFound) {
+		r.client.Logger.Debug().Str("id", state.ID.ValueString()).Msg("Command no longer exists.")
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		r.client.Logger.Debug().Err(err).Msg("Failed to find command")
+		resp.Diagnostics.AddError("Failed to find command", err.Error())
+		return
+	}
+	if !slices.Contains(r.attached(command), int(state.TargetID.ValueInt64())) {
+		r.client.Logger.Debug().Str("id", state.ID.ValueString()).Msgf("Command is no longer attached to %s.", r.target)
+		resp.State.RemoveResource(ctx)
+	}
+}
+
+// Update only stores changed timeouts, every other attribute requires a replacement.
+func (r *commandAttachmentResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	plan, diags := r.get(ctx, req.Plan.GetAttribute)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(r.set(ctx, &resp.State, plan)...)
+}
+
+// Delete detaches the command.
+func (r *commandAttachmentResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	state, diags := r.get(ctx, req.State.GetAttribute)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, state.Timeouts.delete(defaultDeleteTimeout))
+	defer cancel()
+	commandID, targetID := int(state.CommandID.ValueInt64()), int(state.TargetID.ValueInt64())
+	if err := r.detach(ctx, r.client, commandID, targetID); err != nil {
+		r.client.Logger.Debug().Err(err).Msgf("Failed to remove relationship for command and %s.", r.target)
+		resp.Diagnostics.AddError(
+			fmt.Sprintf("Failed to remove relationship for command and %s", r.target),
+			fmt.Sprintf("failed to remove relationship between command %d and %s %d: %s", commandID, r.target, targetID, err),
+		)
+	}
+}
+
+// ImportState imports an attachment from an ID like command_id/repository_id.
+func (r *commandAttachmentResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	commandID, targetID, err := parseAttachmentID(req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Invalid import ID",
+			fmt.Sprintf("expected an ID like command_id/%s, got %q: %s", r.targetIDFieldName(), req.ID, err),
+		)
+		return
+	}
+	resp.Diagnostics.Append(r.set(ctx, &resp.State, commandAttachmentResourceModel{
+		ID:        types.StringValue(attachmentID(commandID, targetID)),
+		CommandID: types.Int64Value(int64(commandID)),
+		TargetID:  types.Int64Value(int64(targetID)),
+	})...)
+}
+
+// attachmentID returns the ID of an attachment, like 1/2.
+func attachmentID(commandID, targetID int) string {
+	return strconv.Itoa(commandID) + "/" + strconv.Itoa(targetID)
+}
+
+// parseAttachmentID returns the command and target ID of an attachment ID.
+func parseAttachmentID(id string) (commandID, targetID int, err error) {
+	c, t, ok := strings.Cut(id, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("missing /")
+	}
+	if commandID, err = strconv.Atoi(c); err != nil {
+		return 0, 0, fmt.Errorf("invalid command ID: %w", err)
+	}
+	if targetID, err = strconv.Atoi(t); err != nil {
+		return 0, 0, fmt.Errorf("invalid ID: %w", err)
+	}
+	return commandID, targetID, nil
+}
@@ -0,0 +1,121 @@
+package krok
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/krok/pkg/models"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+	"github.com/krok-o/terraform-provider-krok/pkg/clients/fake"
+)
+
+func TestParseAttachmentID(t *testing.T) {
+	commandID, targetID, err := parseAttachmentID("12/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commandID != 12 || targetID != 3 {
+		t.Fatalf("expected 12/3, got %d/%d", commandID, targetID)
+	}
+	for _, id := range []string{"12", "12/", "/3", "a/3", "12/b", "12/3/4"} {
+		if _, _, err := parseAttachmentID(id); err == nil {
+			t.Errorf("expected %q to be rejected", id)
+		}
+	}
+}
+
+// attachmentState returns the state of an attachment of the command to the target.
+func attachmentState(t *testing.T, r *commandAttachmentResource, commandID, targetID int) tfsdk.State {
+	t.Helper()
+	ctx := context.Background()
+	var resp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &resp)
+	state := tfsdk.State{Schema: resp.Schema, Raw: tftypes.NewValue(resp.Schema.Type().TerraformType(ctx), nil)}
+	if diags := r.set(ctx, &state, commandAttachmentResourceModel{
+		ID:        types.StringValue(attachmentID(commandID, targetID)),
+		CommandID: types.Int64Value(int64(commandID)),
+		TargetID:  types.Int64Value(int64(targetID)),
+	}); diags.HasError() {
+		t.Fatal(diags)
+	}
+	return state
+}
+
+func TestCommandAttachment(t *testing.T) {
+	ctx := context.Background()
+	server := fake.NewServer()
+	defer server.Close()
+	server.AddCommand(&models.Command{ID: 1, Name: "notify"})
+	client := pkg.NewKrokClient(pkg.Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: fake.APIKeySecret,
+	}, zerolog.Nop())
+
+	for _, newResource := range []func() resource.Resource{NewCommandRepositoryAttachmentResource, NewCommandPlatformAttachmentResource} {
+		r := newResource().(*commandAttachmentResource)
+		r.client = client
+		t.Run(r.target, func(t *testing.T) {
+			state := attachmentState(t, r, 1, 2)
+			read := func() *resource.ReadResponse {
+				t.Helper()
+				resp := &resource.ReadResponse{State: state}
+				r.Read(ctx, resource.ReadRequest{State: state}, resp)
+				for _, d := range resp.Diagnostics {
+					t.Fatalf("%s: %s", d.Summary(), d.Detail())
+				}
+				return resp
+			}
+
+			create := &resource.CreateResponse{State: tfsdk.State{Schema: state.Schema, Raw: tftypes.NewValue(state.Schema.Type().TerraformType(ctx), nil)}}
+			r.Create(ctx, resource.CreateRequest{Plan: tfsdk.Plan{Schema: state.Schema, Raw: state.Raw}}, create)
+			for _, d := range create.Diagnostics {
+				t.Fatalf("%s: %s", d.Summary(), d.Detail())
+			}
+			created, diags := r.get(ctx, create.State.GetAttribute)
+			if diags.HasError() || created.ID.ValueString() != "1/2" {
+				t.Fatalf("expected the attachment 1/2 to be stored, got %+v: %v", created, diags)
+			}
+			if resp := read(); resp.State.Raw.IsNull() {
+				t.Fatal("an existing attachment was removed from the state")
+			}
+
+			del := &resource.DeleteResponse{State: state}
+			r.Delete(ctx, resource.DeleteRequest{State: state}, del)
+			for _, d := range del.Diagnostics {
+				t.Fatalf("%s: %s", d.Summary(), d.Detail())
+			}
+			if resp := read(); !resp.State.Raw.IsNull() {
+				t.Error("a detached attachment should be removed from the state")
+			}
+
+			missing := &resource.CreateResponse{}
+			r.Create(ctx, resource.CreateRequest{Plan: tfsdk.Plan{Schema: state.Schema, Raw: attachmentState(t, r, 3, 2).Raw}}, missing)
+			if !missing.Diagnostics.HasError() {
+				t.Error("attaching a missing command should fail")
+			}
+		})
+	}
+
+	t.Run("deleted command", func(t *testing.T) {
+		r := NewCommandRepositoryAttachmentResource().(*commandAttachmentResource)
+		r.client = client
+		state := attachmentState(t, r, 3, 2)
+		resp := &resource.ReadResponse{State: state}
+		r.Read(ctx, resource.ReadRequest{State: state}, resp)
+		if resp.Diagnostics.HasError() {
+			t.Fatalf("expected no error for a deleted command, got %v", resp.Diagnostics)
+		}
+		if !resp.State.Raw.IsNull() {
+			t.Error("the attachment of a deleted command should be removed from the state")
+		}
+	})
+}
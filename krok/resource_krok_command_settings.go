@@ -0,0 +1,359 @@
+package krok
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/krok-o/krok/pkg/models"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+)
+
+const (
+	commandSettingsSettingsFieldName      = "settings"
+	commandSettingsVaultSettingsFieldName = "vault_settings"
+	commandSettingsExclusiveFieldName     = "exclusive"
+)
+
+var (
+	_ resource.ResourceWithConfigure      = &commandSettingsResource{}
+	_ resource.ResourceWithImportState    = &commandSettingsResource{}
+	_ resource.ResourceWithValidateConfig = &commandSettingsResource{}
+)
+
+// commandSettingsResource manages all settings of a command at once.
+type commandSettingsResource struct {
+	client *pkg.KrokClient
+}
+
+// commandSettingsResourceModel maps the krok_command_settings schema.
+type commandSettingsResourceModel struct {
+	ID            types.String   `tfsdk:"id"`
+	CommandID     types.Int64    `tfsdk:"command_id"`
+	Settings      types.Map      `tfsdk:"settings"`
+	VaultSettings types.Map      `tfsdk:"vault_settings"`
+	Exclusive     types.Bool     `tfsdk:"exclusive"`
+	Timeouts      *timeoutsModel `tfsdk:"timeouts"`
+}
+
+// NewCommandSettingsResource creates the krok_command_settings resource.
+func NewCommandSettingsResource() resource.Resource {
+	return &commandSettingsResource{}
+}
+
+func (r *commandSettingsResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_command_settings"
+}
+
+func (r *commandSettingsResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages the settings of a command. Don't combine it with krok_command_setting for the same command.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			commandSettingsCommandIDFieldName: schema.Int64Attribute{
+				Description: "ID of the command the settings belong to.",
+				Required:    true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+			commandSettingsSettingsFieldName: schema.MapAttribute{
+				Description: "Settings stored in plain text.",
+				Optional:    true,
+				ElementType: types.StringType,
+			},
+			commandSettingsVaultSettingsFieldName: schema.MapAttribute{
+				Description: "Settings stored in the vault of Krok. Krok doesn't return their values, so only changes made through Terraform are detected.",
+				Optional:    true,
+				Sensitive:   true,
+				ElementType: types.StringType,
+			},
+			commandSettingsExclusiveFieldName: schema.BoolAttribute{
+				Description: "Whether settings of the command which aren't configured here are deleted. Defaults to true.",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+			},
+		},
+		Blocks: map[string]schema.Block{
+			timeoutsFieldName: timeoutsBlock(defaultCreateTimeout, defaultReadTimeout, defaultUpdateTimeout, defaultDeleteTimeout),
+		},
+	}
+}
+
+func (r *commandSettingsResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configuredClient(req.ProviderData, &resp.Diagnostics)
+}
+
+// ValidateConfig rejects keys which are set in both settings and vault_settings.
+func (r *commandSettingsResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data commandSettingsResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() || data.Settings.IsUnknown() || data.VaultSettings.IsUnknown() {
+		return
+	}
+	vault := data.VaultSettings.Elements()
+	for key := range data.Settings.Elements() {
+		if _, ok := vault[key]; ok {
+			resp.Diagnostics.AddAttributeError(
+				path.Root(commandSettingsVaultSettingsFieldName).AtMapKey(key),
+				"Duplicate setting",
+				fmt.Sprintf("%q is set in both settings and vault_settings.", key),
+			)
+		}
+	}
+}
+
+// Create creates the configured settings and, if exclusive, deletes all others.
+func (r *commandSettingsResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan commandSettingsResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, plan.Timeouts.create(defaultCreateTimeout))
+	defer cancel()
+	resp.Diagnostics.Append(r.reconcile(ctx, plan, commandSettingsResourceModel{})...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	plan.ID = types.StringValue(strconv.FormatInt(plan.CommandID.ValueInt64(), 10))
+	resp.Diagnostics.Append(r.read(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+// Read refreshes the settings from the Krok server.
+func (r *commandSettingsResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state commandSettingsResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, state.Timeouts.read(defaultReadTimeout))
+	defer cancel()
+	resp.Diagnostics.Append(r.read(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+}
+
+// read stores the settings of the command in data. Unless exclusive, only keys which are already in data are kept.
+// Values of vault settings aren't returned by Krok, so they keep the value in data.
+func (r *commandSettingsResource) read(ctx context.Context, data *commandSettingsResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	existing, err := r.client.SettingsClient.List(ctx, int(data.CommandID.ValueInt64()))
+	if err != nil {
+		r.client.Logger.Debug().Err(err).Msg("Failed to list command settings")
+		diags.AddError("Failed to list command settings", err.Error())
+		return diags
+	}
+	var settings, vault map[string]string
+	diags.Append(data.Settings.ElementsAs(ctx, &settings, false)...)
+	diags.Append(data.VaultSettings.ElementsAs(ctx, &vault, false)...)
+	if diags.HasError() {
+		return diags
+	}
+	exclusive := data.Exclusive.IsNull() || data.Exclusive.ValueBool()
+	newSettings := make(map[string]string)
+	newVault := make(map[string]string)
+	for _, s := range existing {
+		_, plain := settings[s.Key]
+		v, inVault := vault[s.Key]
+		if !exclusive && !plain && !inVault {
+			continue
+		}
+		if s.InVault {
+			newVault[s.Key] = v
+		} else {
+			newSettings[s.Key] = s.Value
+		}
+	}
+	data.Settings = mapValue(ctx, newSettings, data.Settings.IsNull(), &diags)
+	data.VaultSettings = mapValue(ctx, newVault, data.VaultSettings.IsNull(), &diags)
+	return diags
+}
+
+// mapValue returns m as map value. An empty map stays null if it was null before.
+func mapValue(ctx context.Context, m map[string]string, null bool, diags *diag.Diagnostics) types.Map {
+	if len(m) == 0 && null {
+		return types.MapNull(types.StringType)
+	}
+	v, d := types.MapValueFrom(ctx, types.StringType, m)
+	diags.Append(d...)
+	return v
+}
+
+// Update reconciles the settings with the plan.
+func (r *commandSettingsResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan, state commandSettingsResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, plan.Timeouts.update(defaultUpdateTimeout))
+	defer cancel()
+	resp.Diagnostics.Append(r.reconcile(ctx, plan, state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	plan.ID = state.ID
+	resp.Diagnostics.Append(r.read(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+// Delete deletes the settings in state.
+func (r *commandSettingsResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state commandSettingsResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, state.Timeouts.delete(defaultDeleteTimeout))
+	defer cancel()
+	plan := state
+	plan.Settings = types.MapNull(types.StringType)
+	plan.VaultSettings = types.MapNull(types.StringType)
+	plan.Exclusive = types.BoolValue(false)
+	resp.Diagnostics.Append(r.reconcile(ctx, plan, state)...)
+}
+
+func (r *commandSettingsResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	id, err := strconv.ParseInt(req.ID, 10, 64)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid import ID", fmt.Sprintf("expected the ID of a command, got %q: %s", req.ID, err))
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), req.ID)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root(commandSettingsCommandIDFieldName), id)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root(commandSettingsExclusiveFieldName), true)...)
+}
+
+// reconcile creates, updates and deletes settings of the command, so they match plan.
+func (r *commandSettingsResource) reconcile(ctx context.Context, plan, state commandSettingsResourceModel) diag.Diagnostics {
+	var (
+		diags                               diag.Diagnostics
+		settings, vault, managed, prevVault map[string]string
+	)
+	diags.Append(plan.Settings.ElementsAs(ctx, &settings, false)...)
+	diags.Append(plan.VaultSettings.ElementsAs(ctx, &vault, false)...)
+	diags.Append(state.Settings.ElementsAs(ctx, &managed, false)...)
+	diags.Append(state.VaultSettings.ElementsAs(ctx, &prevVault, false)...)
+	if diags.HasError() {
+		return diags
+	}
+	commandID := int(plan.CommandID.ValueInt64())
+	wanted := make(map[string]*models.CommandSetting, len(settings)+len(vault))
+	for k, v := range settings {
+		wanted[k] = &models.CommandSetting{CommandID: commandID, Key: k, Value: v}
+	}
+	for k, v := range vault {
+		wanted[k] = &models.CommandSetting{CommandID: commandID, Key: k, Value: v, InVault: true}
+	}
+	previous := make(map[string]string, len(managed)+len(prevVault))
+	for k, v := range managed {
+		previous[k] = v
+	}
+	for k, v := range prevVault {
+		previous[k] = v
+	}
+
+	existing, err := r.client.SettingsClient.List(ctx, commandID)
+	if err != nil {
+		r.client.Logger.Debug().Err(err).Msg("Failed to list command settings")
+		diags.AddError("Failed to list command settings", err.Error())
+		return diags
+	}
+	changes := diffSettings(existing, wanted, previous, plan.Exclusive.ValueBool())
+	for _, s := range changes.delete {
+		if err := r.client.SettingsClient.Delete(ctx, s.ID); err != nil {
+			r.client.Logger.Debug().Err(err).Str("key", s.Key).Msg("Failed to delete command setting")
+			diags.AddError("Failed to delete command setting", fmt.Sprintf("failed to delete setting %q of command %d: %s", s.Key, commandID, err))
+			return diags
+		}
+	}
+	for _, s := range changes.create {
+		if _, err := r.client.SettingsClient.Create(ctx, s); err != nil {
+			r.client.Logger.Debug().Err(err).Str("key", s.Key).Msg("Failed to create command setting")
+			diags.AddError("Failed to create command setting", fmt.Sprintf("failed to create setting %q of command %d: %s", s.Key, commandID, err))
+			return diags
+		}
+	}
+	for _, s := range changes.update {
+		if err := r.client.SettingsClient.Update(ctx, s); err != nil {
+			r.client.Logger.Debug().Err(err).Str("key", s.Key).Msg("Failed to update command setting")
+			diags.AddError("Failed to update command setting", fmt.Sprintf("failed to update setting %q of command %d: %s", s.Key, commandID, err))
+			return diags
+		}
+	}
+	return diags
+}
+
+// settingChanges are the calls needed to bring the settings of a command in line with the configuration.
+type settingChanges struct {
+	create, update, delete []*models.CommandSetting
+}
+
+// diffSettings compares the existing settings of a command with the wanted ones. previous are the values
+// in state, which are used to detect changes of vault settings, whose values Krok doesn't return.
+// Unless exclusive, only settings which are in previous or wanted are touched.
+// A setting which moves in or out of the vault is deleted and created again.
+func diffSettings(existing []*models.CommandSetting, wanted map[string]*models.CommandSetting, previous map[string]string, exclusive bool) settingChanges {
+	var changes settingChanges
+	found := make(map[string]bool, len(existing))
+	for _, e := range existing {
+		found[e.Key] = true
+		w, ok := wanted[e.Key]
+		_, managed := previous[e.Key]
+		switch {
+		case !ok:
+			if exclusive || managed {
+				changes.delete = append(changes.delete, e)
+			}
+		case w.InVault != e.InVault:
+			changes.delete = append(changes.delete, e)
+			changes.create = append(changes.create, w)
+		case w.InVault && (!managed || previous[e.Key] != w.Value), !w.InVault && e.Value != w.Value:
+			changes.update = append(changes.update, &models.CommandSetting{
+				ID:        e.ID,
+				CommandID: e.CommandID,
+				Key:       e.Key,
+				Value:     w.Value,
+				InVault:   w.InVault,
+			})
+		}
+	}
+	for k, w := range wanted {
+		if !found[k] {
+			changes.create = append(changes.create, w)
+		}
+	}
+	for _, c := range [][]*models.CommandSetting{changes.create, changes.update, changes.delete} {
+		sort.Slice(c, func(i, j int) bool { return c[i].Key < c[j].Key })
+	}
+	return changes
+}
@@ -0,0 +1,67 @@
+package krok
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+func TestDiffSettings(t *testing.T) {
+	existing := []*models.CommandSetting{
+		{ID: 1, CommandID: 5, Key: "channel", Value: "#general"},
+		{ID: 2, CommandID: 5, Key: "token", Value: "", InVault: true},
+		{ID: 3, CommandID: 5, Key: "unmanaged", Value: "x"},
+		{ID: 4, CommandID: 5, Key: "removed", Value: "y"},
+		{ID: 5, CommandID: 5, Key: "secret", Value: "z"},
+		{ID: 6, CommandID: 5, Key: "same", Value: "s"},
+	}
+	wanted := map[string]*models.CommandSetting{
+		"channel": {CommandID: 5, Key: "channel", Value: "#builds"},
+		"token":   {CommandID: 5, Key: "token", Value: "new", InVault: true},
+		"secret":  {CommandID: 5, Key: "secret", Value: "z", InVault: true},
+		"same":    {CommandID: 5, Key: "same", Value: "s"},
+		"added":   {CommandID: 5, Key: "added", Value: "a"},
+	}
+	previous := map[string]string{"channel": "#general", "token": "old", "removed": "y", "secret": "z", "same": "s"}
+
+	keys := func(settings []*models.CommandSetting) (k []string) {
+		for _, s := range settings {
+			k = append(k, s.Key)
+		}
+		return k
+	}
+	for _, tc := range []struct {
+		exclusive                          bool
+		wantCreate, wantUpdate, wantDelete []string
+	}{
+		{
+			exclusive:  false,
+			wantCreate: []string{"added", "secret"},
+			wantUpdate: []string{"channel", "token"},
+			wantDelete: []string{"removed", "secret"},
+		},
+		{
+			exclusive:  true,
+			wantCreate: []string{"added", "secret"},
+			wantUpdate: []string{"channel", "token"},
+			wantDelete: []string{"removed", "secret", "unmanaged"},
+		},
+	} {
+		changes := diffSettings(existing, wanted, previous, tc.exclusive)
+		if got := keys(changes.create); !reflect.DeepEqual(got, tc.wantCreate) {
+			t.Errorf("exclusive=%t: expected creates %v, got %v", tc.exclusive, tc.wantCreate, got)
+		}
+		if got := keys(changes.update); !reflect.DeepEqual(got, tc.wantUpdate) {
+			t.Errorf("exclusive=%t: expected updates %v, got %v", tc.exclusive, tc.wantUpdate, got)
+		}
+		if got := keys(changes.delete); !reflect.DeepEqual(got, tc.wantDelete) {
+			t.Errorf("exclusive=%t: expected deletes %v, got %v", tc.exclusive, tc.wantDelete, got)
+		}
+	}
+	// updates keep the ID of the existing setting.
+	changes := diffSettings(existing, wanted, previous, false)
+	if changes.update[0].ID != 1 || changes.update[0].Value != "#builds" {
+		t.Errorf("expected setting 1 to be updated to #builds, got %+v", changes.update[0])
+	}
+}
@@ -0,0 +1,105 @@
+package krok
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/krok/pkg/models"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+	"github.com/krok-o/terraform-provider-krok/pkg/clients/fake"
+)
+
+func TestResourceCommandCreateKeepsIDOnFailure(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		requests []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		lock.Unlock()
+		switch {
+		case r.URL.Path == "/rest/api/1/krok/command":
+			_, _ = w.Write([]byte(`{"id":5,"name":"test","image":"krokhook/slack-notification:v0.0.1","enabled":true}`))
+		case strings.HasSuffix(r.URL.Path, "/add-command-rel-for-platform/5/2"):
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+	client := pkg.NewKrokClient(pkg.Config{Address: server.URL, Token: "token"}, zerolog.Nop())
+
+	d := schema.TestResourceDataRaw(t, resourceCommand().Schema, map[string]interface{}{
+		commandResourceNameFieldName:      "test",
+		commandResourceImageFieldName:     "krokhook/slack-notification:v0.0.1",
+		commandResourceEnabledFieldName:   true,
+		commandResourcePlatformsFieldName: []interface{}{1, 2},
+	})
+	diags := resourceCommandCreate(context.Background(), d, client)
+	if !diags.HasError() {
+		t.Fatal("expected attaching platform 2 to fail")
+	}
+	if d.Id() != "5" {
+		t.Fatalf("expected the created command to be recorded, got ID %q", d.Id())
+	}
+	// platform 1 has been attached and is detached again.
+	want := "POST /rest/api/1/krok/command/remove-command-rel-for-platform/5/1"
+	if requests[len(requests)-1] != want {
+		t.Fatalf("expected the last request to be %s, got %v", want, requests)
+	}
+}
+
+// importCommand imports the command with the given ID like Terraform does, with the importer followed by a read.
+func importCommand(t *testing.T, client *pkg.KrokClient, id string) *schema.ResourceData {
+	t.Helper()
+	ctx := context.Background()
+	d := schema.TestResourceDataRaw(t, resourceCommand().Schema, map[string]interface{}{})
+	d.SetId(id)
+	imported, err := resourceCommand().Importer.StateContext(ctx, d, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 1 {
+		t.Fatalf("expected one imported command, got %d", len(imported))
+	}
+	if diags := resourceCommandRead(ctx, imported[0], client); diags.HasError() {
+		t.Fatal(diags)
+	}
+	return imported[0]
+}
+
+func TestResourceCommandImport(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	server.AddCommand(&models.Command{
+		ID:        4,
+		Name:      "notify",
+		Image:     "krokhook/slack-notification:v0.0.1",
+		Enabled:   true,
+		Platforms: []models.Platform{models.SupportedPlatforms[models.GITHUB], models.SupportedPlatforms[models.GITLAB]},
+	})
+	client := pkg.NewKrokClient(pkg.Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: fake.APIKeySecret,
+	}, zerolog.Nop())
+
+	d := importCommand(t, client, "4")
+	platforms := d.Get(commandResourcePlatformsFieldName).([]interface{})
+	if len(platforms) != 2 || platforms[0] != models.GITHUB || platforms[1] != models.GITLAB {
+		t.Errorf("the platforms of an imported command should be managed by it, got %v", platforms)
+	}
+	if d.Get(commandResourceNameFieldName) != "notify" {
+		t.Errorf("the command was not read, got name %v", d.Get(commandResourceNameFieldName))
+	}
+}
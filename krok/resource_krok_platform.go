@@ -1,10 +1,15 @@
 package krok
 
 import (
-	"fmt"
-	"log"
+	"context"
 
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 
 	"github.com/krok-o/krok/pkg/models"
 
@@ -12,78 +17,183 @@ import (
 )
 
 const (
-	platformTokenFieldName = "token"
-	platformVCSFieldName   = "vcs"
+	platformTokenFieldName          = "token"
+	platformTokenWOFieldName        = "token_wo"
+	platformTokenWOVersionFieldName = "token_wo_version"
+	platformVCSFieldName            = "vcs"
 )
 
-func resourcePlatform() *schema.Resource {
-	return &schema.Resource{
-		Create: resourcePlatformCreate,
-		Read:   resourcePlatformRead,
-		Update: resourcePlatformUpdate,
-		Delete: resourcePlatformDelete,
-
-		Schema: map[string]*schema.Schema{
-			platformTokenFieldName: {
-				Type:     schema.TypeString,
-				Required: true,
+var (
+	_ resource.ResourceWithConfigure      = &platformResource{}
+	_ resource.ResourceWithValidateConfig = &platformResource{}
+)
+
+// platformResource manages the token Krok uses to access a VCS platform.
+type platformResource struct {
+	client *pkg.KrokClient
+}
+
+// platformResourceModel maps the krok_platform schema.
+type platformResourceModel struct {
+	ID             types.String   `tfsdk:"id"`
+	Token          types.String   `tfsdk:"token"`
+	TokenWO        types.String   `tfsdk:"token_wo"`
+	TokenWOVersion types.Int64    `tfsdk:"token_wo_version"`
+	VCS            types.Int64    `tfsdk:"vcs"`
+	Timeouts       *timeoutsModel `tfsdk:"timeouts"`
+}
+
+// NewPlatformResource creates the krok_platform resource.
+func NewPlatformResource() resource.Resource {
+	return &platformResource{}
+}
+
+func (r *platformResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_platform"
+}
+
+func (r *platformResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			platformTokenFieldName: schema.StringAttribute{
+				Description: "Token used to access the platform. Stored in the state, see token_wo for an alternative.",
+				Optional:    true,
+				Sensitive:   true,
 			},
-			platformVCSFieldName: {
-				Type:     schema.TypeInt,
-				Required: true,
+			platformTokenWOFieldName: schema.StringAttribute{
+				Description: "Token used to access the platform which is never stored in the plan or the state. Requires Terraform 1.11 or later.",
+				Optional:    true,
+				WriteOnly:   true,
+				Sensitive:   true,
 			},
+			platformTokenWOVersionFieldName: schema.Int64Attribute{
+				Description: "Change this value to send an updated token_wo to Krok.",
+				Optional:    true,
+			},
+			platformVCSFieldName: schema.Int64Attribute{
+				Description: "ID of the platform the token belongs to.",
+				Required:    true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+		},
+		Blocks: map[string]schema.Block{
+			timeoutsFieldName: timeoutsBlock(defaultCreateTimeout, defaultReadTimeout, defaultUpdateTimeout, defaultDeleteTimeout),
 		},
 	}
 }
 
-// resourcePlatformCreate creates a Krok platform.
-func resourcePlatformCreate(d *schema.ResourceData, m interface{}) error {
-	client := m.(*pkg.KrokClient)
-	expandedVCSToken, err := expandVCSTokenResource(d)
-	if err != nil {
-		return err
+func (r *platformResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configuredClient(req.ProviderData, &resp.Diagnostics)
+}
+
+// ValidateConfig makes sure exactly one of token and token_wo is set.
+func (r *platformResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var config platformResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if config.Token.IsUnknown() || config.TokenWO.IsUnknown() {
+		return
+	}
+	if config.Token.IsNull() == config.TokenWO.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root(platformTokenFieldName),
+			"Invalid platform token",
+			"Exactly one of token or token_wo must be set.",
+		)
 	}
-	if err := client.VcsClient.Create(expandedVCSToken); err != nil {
-		log.Println("Failed to create vcstoken.")
-		return fmt.Errorf("failed to create vcstoken: %w", err)
+	if !config.TokenWOVersion.IsNull() && config.TokenWO.IsNull() {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root(platformTokenWOVersionFieldName),
+			"Unused token version",
+			"token_wo_version only has an effect together with token_wo.",
+		)
 	}
-	d.SetId(uniqueResourceID())
-	return resourceCommandRead(d, m)
 }
 
-// expandVCSTokenResource creates a Krok vcstoken structure out of a Terraform schema model.
-func expandVCSTokenResource(d *schema.ResourceData) (*models.VCSToken, error) {
-	var (
-		token string
-		vcs   int
-	)
-	if v, ok := d.GetOk(platformTokenFieldName); ok {
-		token = v.(string)
-	} else {
-		return nil, fmt.Errorf("unable to find parse field %s", platformTokenFieldName)
+// Create creates a Krok platform token.
+func (r *platformResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan, config platformResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
-	if v, ok := d.GetOk(platformVCSFieldName); ok {
-		vcs = v.(int)
-	} else {
-		return nil, fmt.Errorf("unable to find parse field %s", platformVCSFieldName)
+	ctx, cancel := context.WithTimeout(ctx, plan.Timeouts.create(defaultCreateTimeout))
+	defer cancel()
+	if err := r.client.VcsClient.Create(ctx, expandVCSToken(plan, config)); err != nil {
+		r.client.Logger.Debug().Err(err).Msg("Failed to create vcstoken.")
+		resp.Diagnostics.AddError("Failed to create vcstoken", err.Error())
+		return
 	}
-	platform := &models.VCSToken{
+	plan.ID = types.StringValue(uniqueResourceID())
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+}
+
+// expandVCSToken creates a Krok vcstoken structure out of the planned values. Write-only
+// values are only available in the configuration.
+func expandVCSToken(plan, config platformResourceModel) *models.VCSToken {
+	token := plan.Token.ValueString()
+	if !config.TokenWO.IsNull() {
+		token = config.TokenWO.ValueString()
+	}
+	return &models.VCSToken{
 		Token: token,
-		VCS:   vcs,
+		VCS:   int(plan.VCS.ValueInt64()),
 	}
-	return platform, nil
 }
 
-// resourcePlatformUpdate updates platform information from terraform stores.
-func resourcePlatformUpdate(d *schema.ResourceData, m interface{}) error {
-	return nil
+// Update sends the token again if it, or the version of the write-only token, changed.
+func (r *platformResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan, state, config platformResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, plan.Timeouts.update(defaultUpdateTimeout))
+	defer cancel()
+	if !plan.Token.Equal(state.Token) || !plan.TokenWOVersion.Equal(state.TokenWOVersion) {
+		if err := r.client.VcsClient.Create(ctx, expandVCSToken(plan, config)); err != nil {
+			r.client.Logger.Debug().Err(err).Msg("Failed to update vcstoken.")
+			resp.Diagnostics.AddError("Failed to update vcstoken", err.Error())
+			return
+		}
+	}
+	plan.ID = state.ID
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
 }
 
-// resourcePlatformRead retrieves platform information from terraform stores.
-func resourcePlatformRead(d *schema.ResourceData, m interface{}) error {
-	return nil
+// Read keeps the platform token as is. Krok doesn't return or list vcs tokens, so whether the
+// platform still has the token can't be checked.
+func (r *platformResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state platformResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.client.Logger.Debug().Int64("vcs", state.VCS.ValueInt64()).Msg("Krok can't list vcs tokens, keeping the platform token as is.")
 }
 
-func resourcePlatformDelete(d *schema.ResourceData, m interface{}) error {
-	return nil
+// Delete only removes the token from the state, because Krok can't delete vcs tokens.
+func (r *platformResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state platformResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.AddWarning(
+		"Platform token not deleted",
+		"The Krok server doesn't support deleting vcs tokens, the token is only removed from the state.",
+	)
 }
@@ -1,12 +1,23 @@
 package krok
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"slices"
 	"strconv"
+	"strings"
 
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/objectplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 
 	"github.com/krok-o/krok/pkg/models"
 
@@ -23,374 +34,724 @@ const (
 	repoAuthFieldName            = "auth"
 	repoAuthSecretFieldName      = "secret"
 	repoCommandsFieldName        = "commands"
-	// repoEventsFieldName                      = "name"
+	repoUniqueURLFieldName       = "unique_url"
+	repoGenerateSecretFieldName  = "generate_secret"
+	repoSecretLengthFieldName    = "length"
+	repoSecretCharsetFieldName   = "charset"
+	repoSecretVersionFieldName   = "secret_version"
+	repoSecretFieldName          = "secret"
+	repoSecretHashFieldName      = "secret_hash"
 )
 
-func resourceRepository() *schema.Resource {
-	return &schema.Resource{
-		Create: resourceRepositoryCreate,
-		Read:   resourceRepositoryRead,
-		Update: resourceRepositoryUpdate,
-		Delete: resourceRepositoryDelete,
+var (
+	_ resource.ResourceWithConfigure      = &repositoryResource{}
+	_ resource.ResourceWithImportState    = &repositoryResource{}
+	_ resource.ResourceWithUpgradeState   = &repositoryResource{}
+	_ resource.ResourceWithValidateConfig = &repositoryResource{}
+)
+
+// repositoryResource manages a Krok repository and its command relationships.
+type repositoryResource struct {
+	client *pkg.KrokClient
+}
+
+// repositoryResourceModel maps the krok_repository schema.
+type repositoryResourceModel struct {
+	ID             types.String                   `tfsdk:"id"`
+	Name           types.String                   `tfsdk:"name"`
+	URL            types.String                   `tfsdk:"url"`
+	VCS            types.Int64                    `tfsdk:"vcs"`
+	Commands       types.Set                      `tfsdk:"commands"`
+	Events         types.List                     `tfsdk:"events"`
+	Auth           *repositoryAuthModel           `tfsdk:"auth"`
+	GitLab         *repositoryGitlabModel         `tfsdk:"gitlab"`
+	UniqueURL      types.String                   `tfsdk:"unique_url"`
+	GenerateSecret *repositoryGenerateSecretModel `tfsdk:"generate_secret"`
+	SecretVersion  types.Int64                    `tfsdk:"secret_version"`
+	Secret         types.String                   `tfsdk:"secret"`
+	SecretHash     types.String                   `tfsdk:"secret_hash"`
+	Timeouts       *timeoutsModel                 `tfsdk:"timeouts"`
+}
+
+// repositoryAuthModel maps the auth attribute of krok_repository.
+type repositoryAuthModel struct {
+	Secret types.String `tfsdk:"secret"`
+}
+
+// repositoryGitlabModel maps the gitlab attribute of krok_repository.
+type repositoryGitlabModel struct {
+	ProjectID types.Int64 `tfsdk:"project_id"`
+}
+
+// repositoryGenerateSecretModel maps the generate_secret attribute of krok_repository.
+type repositoryGenerateSecretModel struct {
+	Length  types.Int64  `tfsdk:"length"`
+	Charset types.String `tfsdk:"charset"`
+}
+
+// options returns the length and charset of the generated secret, using the defaults for unset values.
+func (m *repositoryGenerateSecretModel) options() (int, string) {
+	length, charset := defaultSecretLength, defaultSecretCharset
+	if !m.Length.IsNull() && !m.Length.IsUnknown() {
+		length = int(m.Length.ValueInt64())
+	}
+	if !m.Charset.IsNull() && !m.Charset.IsUnknown() {
+		charset = m.Charset.ValueString()
+	}
+	return length, charset
+}
+
+// NewRepositoryResource creates the krok_repository resource.
+func NewRepositoryResource() resource.Resource {
+	return &repositoryResource{}
+}
+
+func (r *repositoryResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_repository"
+}
 
-		Schema: map[string]*schema.Schema{
-			repoNameFieldName: {
-				Type:        schema.TypeString,
+func (r *repositoryResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Version: 2,
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			repoNameFieldName: schema.StringAttribute{
 				Description: "Name of the repository.",
 				Required:    true,
 			},
-			repoURLFieldName: {
-				Type:        schema.TypeString,
+			repoURLFieldName: schema.StringAttribute{
 				Description: "The URL to the repository.",
 				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
 			},
-			repoVCSFieldName: {
-				Type:        schema.TypeInt,
+			repoVCSFieldName: schema.Int64Attribute{
 				Description: "ID of the platform this repository is located on.",
 				Required:    true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
 			},
-			repoCommandsFieldName: {
-				Type:        schema.TypeList,
-				Description: "List of IDs of commands that this repository should run in case of an event.",
+			repoCommandsFieldName: schema.SetAttribute{
+				Description: "Set of IDs of commands that this repository should run in case of an event. Leave it unset to attach commands with krok_command_repository_attachment instead.",
 				Optional:    true,
-				Elem: &schema.Schema{
-					Type: schema.TypeInt,
-				},
+				ElementType: types.Int64Type,
 			},
-			repoEventsFieldName: {
-				Type:        schema.TypeList,
+			repoEventsFieldName: schema.ListAttribute{
 				Description: "Events to which this repository subscribes to. Exp: push for Github.",
 				Required:    true,
-				Elem: &schema.Schema{
-					Type: schema.TypeString,
+				ElementType: types.StringType,
+			},
+			repoUniqueURLFieldName: schema.StringAttribute{
+				Description: "The URL Krok receives the events of this repository on, to be configured as webhook on the platform.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
 				},
 			},
-
-			repoAuthFieldName: {
-				Type:        schema.TypeList,
-				Required:    true,
-				MaxItems:    1,
-				Description: "Contains sensitive information.",
-				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
-					return old == "1" && new == "0"
+			repoAuthFieldName: schema.SingleNestedAttribute{
+				Description: "Contains sensitive information. Conflicts with generate_secret.",
+				Optional:    true,
+				Attributes: map[string]schema.Attribute{
+					repoAuthSecretFieldName: schema.StringAttribute{
+						Description: "The secret of the webhook that is generated for verification.",
+						Required:    true,
+						Sensitive:   true,
+					},
 				},
-				Elem: &schema.Resource{
-					Schema: map[string]*schema.Schema{
-						repoAuthSecretFieldName: {
-							Type:        schema.TypeString,
-							Description: "The secret of the webhook that is generated for verification.",
-							Required:    true,
-						},
+			},
+			repoGenerateSecretFieldName: schema.SingleNestedAttribute{
+				Description: "Let the provider generate the webhook secret instead of setting it in auth. Conflicts with auth.",
+				Optional:    true,
+				Attributes: map[string]schema.Attribute{
+					repoSecretLengthFieldName: schema.Int64Attribute{
+						Description: fmt.Sprintf("Length of the secret, between %d and %d. Defaults to %d.", minSecretLength, maxSecretLength, defaultSecretLength),
+						Optional:    true,
+					},
+					repoSecretCharsetFieldName: schema.StringAttribute{
+						Description: "Characters the secret is made of. Defaults to letters and digits.",
+						Optional:    true,
 					},
 				},
 			},
-			repoGitlabFieldName: {
-				Type:        schema.TypeList,
+			repoSecretVersionFieldName: schema.Int64Attribute{
+				Description: "Change this value to generate a new secret.",
 				Optional:    true,
+			},
+			repoSecretFieldName: schema.StringAttribute{
+				Description: "The secret of the webhook, either the one in auth or the generated one.",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			repoSecretHashFieldName: schema.StringAttribute{
+				Description: "The hex encoded SHA-256 sum of secret, which shows a changed secret in plans without revealing it.",
+				Computed:    true,
+			},
+			repoGitlabFieldName: schema.SingleNestedAttribute{
 				Description: "In case of gitlab platform these are gitlab specific settings.",
-				MaxItems:    1,
-				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
-					return old == "1" && new == "0"
+				Optional:    true,
+				PlanModifiers: []planmodifier.Object{
+					objectplanmodifier.RequiresReplace(),
 				},
-				Elem: &schema.Resource{
-					Schema: map[string]*schema.Schema{
-						repoGitlabProjectIDFieldName: {
-							Type:        schema.TypeInt,
-							Description: "ID of the Gitlab project.",
-							Required:    true,
-						},
+				Attributes: map[string]schema.Attribute{
+					repoGitlabProjectIDFieldName: schema.Int64Attribute{
+						Description: "ID of the Gitlab project.",
+						Required:    true,
 					},
 				},
 			},
 		},
+		Blocks: map[string]schema.Block{
+			timeoutsFieldName: timeoutsBlock(defaultCreateTimeout, defaultReadTimeout, defaultUpdateTimeout, defaultDeleteTimeout),
+		},
 	}
 }
 
-// resourceRepositoryCreate creates a Krok repository.
-func resourceRepositoryCreate(d *schema.ResourceData, m interface{}) error {
-	client := m.(*pkg.KrokClient)
-	expandedRepo, err := expandRepositoryResource(client, d)
-	if err != nil {
-		return err
+func (r *repositoryResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configuredClient(req.ProviderData, &resp.Diagnostics)
+}
+
+// ValidateConfig checks that the secret is either set or generated.
+func (r *repositoryResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var (
+		auth, generate types.Object
+		version        types.Int64
+	)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root(repoAuthFieldName), &auth)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root(repoGenerateSecretFieldName), &generate)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root(repoSecretVersionFieldName), &version)...)
+	if resp.Diagnostics.HasError() || auth.IsUnknown() || generate.IsUnknown() {
+		return
 	}
-	repo, err := client.RepositoryClient.Create(expandedRepo)
-	if err != nil {
-		log.Println("Failed to create repository.")
-		return fmt.Errorf("failed to create repository: %w", err)
+	if auth.IsNull() == generate.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root(repoAuthFieldName),
+			"Invalid webhook secret",
+			"Exactly one of auth and generate_secret must be set.",
+		)
 	}
+	if !version.IsNull() && generate.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root(repoSecretVersionFieldName),
+			"Invalid secret version",
+			"secret_version only applies to secrets created with generate_secret.",
+		)
+	}
+	if generate.IsNull() {
+		return
+	}
+	var options repositoryGenerateSecretModel
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root(repoGenerateSecretFieldName), &options)...)
+	if resp.Diagnostics.HasError() || options.Length.IsUnknown() || options.Charset.IsUnknown() {
+		return
+	}
+	if err := validateSecretOptions(options.options()); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root(repoGenerateSecretFieldName), "Invalid secret options", err.Error())
+	}
+}
 
-	// add any relationships that might exist for commands.
-	for _, c := range repo.Commands {
-		if err := client.CommandClient.AddRelationshipToRepository(c.ID, repo.ID); err != nil {
-			log.Println("Failed to create relationship for command and repository.")
-			return fmt.Errorf("failed to add relationship between command %d and repo %d: %w", c.ID, repo.ID, err)
-		}
+// ModifyPlan checks the planned repository against the Krok server.
+func (r *repositoryResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+	r.planSecret(ctx, req, resp)
+	// nothing to check against the server if the provider isn't configured yet.
+	if resp.Diagnostics.HasError() || r.client == nil {
+		return
+	}
+	var (
+		vcs      types.Int64
+		events   types.List
+		commands types.Set
+	)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root(repoVCSFieldName), &vcs)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root(repoEventsFieldName), &events)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root(repoCommandsFieldName), &commands)...)
+	if resp.Diagnostics.HasError() || vcs.IsUnknown() {
+		return
+	}
+	if !events.IsUnknown() {
+		r.validateEvents(ctx, vcs, events, &resp.Diagnostics)
+	}
+	if !commands.IsUnknown() {
+		r.validateCommands(ctx, vcs, commands, &resp.Diagnostics)
 	}
+}
 
-	d.SetId(strconv.Itoa(repo.ID))
-	return resourceRepositoryRead(d, m)
+// planSecret plans the webhook secret. A generated secret is kept until generate_secret or secret_version change.
+func (r *repositoryResource) planSecret(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	var plan, state repositoryResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if !req.State.Raw.IsNull() {
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	secret := types.StringUnknown()
+	switch {
+	case plan.Auth != nil:
+		secret = plan.Auth.Secret
+	case req.State.Raw.IsNull() || state.Auth != nil || state.GenerateSecret == nil:
+		// the secret is generated for the first time.
+	case plan.GenerateSecret.equal(state.GenerateSecret) && plan.SecretVersion.Equal(state.SecretVersion):
+		secret = state.Secret
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root(repoSecretFieldName), secret)...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root(repoSecretHashFieldName), hashSecret(secret))...)
 }
 
-// authFields is a convenient wrapper around the auth schema for easy parsing
-type authFields struct {
-	secret string
+// equal returns whether both models generate secrets the same way.
+func (m *repositoryGenerateSecretModel) equal(o *repositoryGenerateSecretModel) bool {
+	if m == nil || o == nil {
+		return m == o
+	}
+	return m.Length.Equal(o.Length) && m.Charset.Equal(o.Charset)
 }
 
-// gitlabFields is a convenient wrapper around the gitlab schema for easy parsing
-type gitlabFields struct {
-	projectID int
+// generateRepositorySecret generates a secret for data if a new one is planned.
+func generateRepositorySecret(data *repositoryResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if data.Auth != nil || data.GenerateSecret == nil || !data.Secret.IsUnknown() {
+		return diags
+	}
+	secret, err := generateSecret(data.GenerateSecret.options())
+	if err != nil {
+		diags.AddAttributeError(path.Root(repoGenerateSecretFieldName), "Failed to generate secret", err.Error())
+		return diags
+	}
+	data.Secret = types.StringValue(secret)
+	data.SecretHash = hashSecret(data.Secret)
+	return diags
 }
 
-// expandRepositoryResource creates a Krok repository structure out of a Terraform schema model.
-func expandRepositoryResource(client *pkg.KrokClient, d *schema.ResourceData) (*models.Repository, error) {
-	var (
-		name   string
-		url    string
-		vcs    int
-		gitlab gitlabFields
-		auth   authFields
-		err    error
-		events []string
-	)
-	if v, ok := d.GetOk(repoNameFieldName); ok {
-		name = v.(string)
-	} else {
-		return nil, fmt.Errorf("unable to find or parse field %s", repoNameFieldName)
-	}
-	if v, ok := d.GetOk(repoURLFieldName); ok {
-		url = v.(string)
-	} else {
-		return nil, fmt.Errorf("unable to find or parse field %s", repoURLFieldName)
-	}
-	if v, ok := d.GetOk(repoVCSFieldName); ok {
-		vcs = v.(int)
-	} else {
-		return nil, fmt.Errorf("unable to find or parse field %s", repoVCSFieldName)
-	}
-	if v, ok := d.GetOk(repoAuthFieldName); ok {
-		if auth, err = expandAuth(v.([]interface{})); err != nil {
-			return nil, err
+// validateCommands warns about commands which already exist but aren't enabled or don't run for the
+// repository's platform, because Krok silently skips them. These are only warnings, since the command
+// can be changed in the same apply.
+func (r *repositoryResource) validateCommands(ctx context.Context, vcs types.Int64, commands types.Set, diags *diag.Diagnostics) {
+	var values []types.Int64
+	diags.Append(commands.ElementsAs(ctx, &values, true)...)
+	// commands which are created in the same plan don't have an ID yet.
+	var ids []int64
+	for _, v := range values {
+		if !v.IsUnknown() && !v.IsNull() {
+			ids = append(ids, v.ValueInt64())
 		}
 	}
-	if v, ok := d.GetOk(repoEventsFieldName); ok {
-		for _, e := range v.([]interface{}) {
-			events = append(events, e.(string))
+	expanded, d := expandCommands(ctx, r.client, ids)
+	diags.Append(d...)
+	if d.HasError() {
+		return
+	}
+	for _, c := range expanded {
+		p := path.Root(repoCommandsFieldName).AtSetValue(types.Int64Value(int64(c.ID)))
+		if !c.Enabled {
+			diags.AddAttributeWarning(p, "Command is disabled",
+				fmt.Sprintf("Command %q (%d) is disabled and won't run for events of this repository.", c.Name, c.ID),
+			)
+		}
+		if !slices.ContainsFunc(c.Platforms, func(platform models.Platform) bool {
+			return platform.ID == int(vcs.ValueInt64())
+		}) {
+			diags.AddAttributeWarning(p, "Command doesn't support the repository's platform",
+				fmt.Sprintf("Command %q (%d) isn't enabled for platform %d yet, so Krok ignores it for events of this repository "+
+					"unless the platform is added to the platforms of the command.", c.Name, c.ID, vcs.ValueInt64()),
+			)
 		}
-	} else {
-		return nil, fmt.Errorf("unable to find or parse field %s", repoEventsFieldName)
 	}
-	repo := &models.Repository{
-		Name: name,
-		URL:  url,
-		VCS:  vcs,
-		Auth: &models.Auth{
-			Secret: auth.secret,
-		},
-		Events: events,
+}
+
+// validateEvents checks that every event is a webhook event of the repository's platform.
+func (r *repositoryResource) validateEvents(ctx context.Context, vcs types.Int64, events types.List, diags *diag.Diagnostics) {
+	platform, err := r.client.PlatformClient.Get(ctx, int(vcs.ValueInt64()))
+	if err != nil {
+		r.client.Logger.Debug().Err(err).Int64("vcs", vcs.ValueInt64()).Msg("Failed to get platform")
+		diags.AddAttributeWarning(
+			path.Root(repoVCSFieldName),
+			"Unable to validate events",
+			fmt.Sprintf("failed to get platform %d: %s", vcs.ValueInt64(), err),
+		)
+		return
 	}
-	if v, ok := d.GetOk(repoCommandsFieldName); ok {
-		commands, err := expandCommands(client, v.([]interface{}))
-		if err != nil {
-			return nil, err
-		}
-		repo.Commands = commands
+	allowed, ok := platformEvents[strings.ToLower(platform.Name)]
+	if !ok {
+		r.client.Logger.Debug().Str("platform", platform.Name).Msg("No known events for platform, skipping validation.")
+		return
 	}
-	if v, ok := d.GetOk(repoGitlabFieldName); ok {
-		if gitlab, err = expandGitlab(v.([]interface{})); err != nil {
-			return nil, err
+	var values []types.String
+	diags.Append(events.ElementsAs(ctx, &values, false)...)
+	for i, e := range values {
+		if e.IsUnknown() || e.IsNull() || slices.Contains(allowed, e.ValueString()) {
+			continue
 		}
-		repo.GitLab = &models.GitLab{
-			ProjectID: gitlab.projectID,
+		detail := fmt.Sprintf("%q is not a webhook event of %s.", e.ValueString(), platform.Name)
+		if s := suggestEvent(e.ValueString(), allowed); s != "" {
+			detail += fmt.Sprintf(" Did you mean %q?", s)
 		}
+		diags.AddAttributeError(path.Root(repoEventsFieldName).AtListIndex(i), "Invalid event", detail)
 	}
-	return repo, nil
 }
 
-// expandAuth gathers auth data from the Terraform store
-func expandAuth(s []interface{}) (auth authFields, err error) {
-	for _, v := range s {
-		item := v.(map[string]interface{})
-		if i, ok := item[repoAuthSecretFieldName]; ok {
-			auth.secret = i.(string)
-		} else {
-			return auth, errors.New("secret field not found in auth")
-		}
+// Create creates a Krok repository.
+func (r *repositoryResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan repositoryResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(generateRepositorySecret(&plan)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
-	return
+	ctx, cancel := context.WithTimeout(ctx, plan.Timeouts.create(defaultCreateTimeout))
+	defer cancel()
+	expandedRepo, diags := expandRepository(ctx, r.client, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	repo, err := r.client.RepositoryClient.Create(ctx, expandedRepo)
+	if err != nil {
+		r.client.Logger.Debug().Err(err).Msg("Failed to create repository.")
+		resp.Diagnostics.AddError("Failed to create repository", err.Error())
+		return
+	}
+	// from here on the repository exists, so it's stored even if a later step fails.
+	plan.ID = types.StringValue(strconv.Itoa(repo.ID))
+
+	// add any relationships that might exist for commands.
+	changes := make([]relationshipChange, 0, len(expandedRepo.Commands))
+	for _, c := range expandedRepo.Commands {
+		changes = append(changes, r.commandChange(ctx, repo.ID, c.ID, true))
+	}
+	resp.Diagnostics.Append(r.applyCommandChanges(changes)...)
+	resp.Diagnostics.Append(r.storeCreated(ctx, &plan, &resp.State)...)
 }
 
-// expandAuth gathers gitlab data from the Terraform store
-func expandGitlab(s []interface{}) (gitlab gitlabFields, err error) {
-	for _, v := range s {
-		item := v.(map[string]interface{})
-		if i, ok := item[repoGitlabProjectIDFieldName]; ok {
-			gitlab.projectID = i.(int)
-		} else {
-			return gitlab, errors.New("if gitlab is defined, project ID must be provided")
+// storeCreated reads a created repository into data and stores it. It's stored even if Create or the read
+// failed, in which case Terraform marks it as tainted, so the next apply replaces it instead of creating
+// a duplicate.
+func (r *repositoryResource) storeCreated(ctx context.Context, data *repositoryResourceModel, state *tfsdk.State) diag.Diagnostics {
+	diags := r.read(ctx, data)
+	if diags.HasError() {
+		// keep the planned values, but nothing which is only known after the repository has been read.
+		if data.UniqueURL.IsUnknown() {
+			data.UniqueURL = types.StringNull()
 		}
+		if data.Secret.IsUnknown() {
+			data.Secret = types.StringNull()
+		}
+		data.SecretHash = hashSecret(data.Secret)
 	}
-	return
+	diags.Append(state.Set(ctx, data)...)
+	return diags
 }
 
-// expandCommands gathers all commands for which the IDs have been defined.
-func expandCommands(client *pkg.KrokClient, s []interface{}) (commands []*models.Command, err error) {
-	for _, v := range s {
-		command, err := client.CommandClient.Get(v.(int))
-		if err != nil {
-			return nil, fmt.Errorf("failed to retrieve command with id %d with error: %w", v, err)
+// Read retrieves repository information from the Krok server, and removes the resource if the repository was deleted.
+func (r *repositoryResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state repositoryResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, state.Timeouts.read(defaultReadTimeout))
+	defer cancel()
+	rid, err := strconv.Atoi(state.ID.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("id"), "Invalid repository ID", err.Error())
+		return
+	}
+	repo, err := r.client.RepositoryClient.Get(ctx, rid)
+	if errors.Is(err, pkg.ErrNotFound) {
+		r.client.Logger.Debug().Str("id", state.ID.ValueString()).Msg("Repository no longer exists.")
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		r.client.Logger.Debug().Err(err).Msg("Failed to find repository")
+		resp.Diagnostics.AddError("Failed to find repository", err.Error())
+		return
+	}
+	previous := state.Commands
+	// a repository which has just been imported has no commands yet which could have been attached since.
+	imported := state.URL.IsNull()
+	resp.Diagnostics.Append(flattenRepository(ctx, repo, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if !imported {
+		resp.Diagnostics.Append(detectAttachedCommands(previous, state.Commands)...)
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+}
+
+// detectAttachedCommands warns about commands which have been attached to the repository since the
+// last refresh, most likely by a krok_command_repository_attachment. They are removed again on the
+// next apply, because the commands of krok_repository are authoritative.
+func detectAttachedCommands(previous, current types.Set) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if previous.IsNull() || current.IsNull() {
+		return diags
+	}
+	for _, c := range current.Elements() {
+		if slices.Contains(previous.Elements(), c) {
+			continue
 		}
-		commands = append(commands, command)
+		diags.AddAttributeWarning(
+			path.Root(repoCommandsFieldName).AtSetValue(c),
+			"Command attached outside of krok_repository",
+			fmt.Sprintf("Command %s has been attached to this repository outside of its commands, for example by a "+
+				"krok_command_repository_attachment. It will be detached on the next apply. Either add it to commands "+
+				"or remove commands and manage every relationship with krok_command_repository_attachment.", c),
+		)
 	}
-	return
+	return diags
 }
 
-// resourceRepositoryRead retrieves repository information from terraform stores.
-func resourceRepositoryRead(d *schema.ResourceData, m interface{}) error {
-	client := m.(*pkg.KrokClient)
-	rid, err := strconv.Atoi(d.Id())
+// read refreshes data with the repository stored on the Krok server.
+func (r *repositoryResource) read(ctx context.Context, data *repositoryResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	rid, err := strconv.Atoi(data.ID.ValueString())
 	if err != nil {
-		return err
+		diags.AddAttributeError(path.Root("id"), "Invalid repository ID", err.Error())
+		return diags
 	}
-	repo, err := client.RepositoryClient.Get(rid)
+	repo, err := r.client.RepositoryClient.Get(ctx, rid)
 	if err != nil {
-		log.Println("Failed to find repository")
-		d.SetId("")
-		return err
+		r.client.Logger.Debug().Err(err).Msg("Failed to find repository")
+		diags.AddError("Failed to find repository", err.Error())
+		return diags
 	}
+	diags.Append(flattenRepository(ctx, repo, data)...)
+	return diags
+}
 
-	for k, v := range flattenRepository(repo) {
-		if err := d.Set(k, v); err != nil {
-			d.SetId("")
-			return err
+// Update checks fields for differences and updates a repository if necessary.
+func (r *repositoryResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan, state repositoryResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	resp.Diagnostics.Append(generateRepositorySecret(&plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, plan.Timeouts.update(defaultUpdateTimeout))
+	defer cancel()
+	rid, err := strconv.Atoi(state.ID.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("id"), "Invalid repository ID", err.Error())
+		return
+	}
+	repo, err := r.client.RepositoryClient.Get(ctx, rid)
+	if err != nil {
+		r.client.Logger.Debug().Err(err).Msg("Failed to find repository")
+		resp.Diagnostics.AddError("Failed to find repository", err.Error())
+		return
+	}
+	expandedRepo, diags := expandRepository(ctx, r.client, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// look through all relationships of the repo and add what's missing and delete what has been removed.
+	// without configured commands the relationships are left to krok_command_repository_attachment.
+	if !plan.Commands.IsNull() {
+		resp.Diagnostics.Append(r.reconcileCommands(ctx, repo, expandedRepo.Commands)...)
+		if resp.Diagnostics.HasError() {
+			return
 		}
 	}
-	return nil
+
+	repo.Name = expandedRepo.Name
+	repo.Events = expandedRepo.Events
+	repo.Auth = expandedRepo.Auth
+	if _, err := r.client.RepositoryClient.Update(ctx, repo); err != nil {
+		r.client.Logger.Debug().Err(err).Msg("Failed to update repository")
+		resp.Diagnostics.AddError("Failed to update repository", err.Error())
+		return
+	}
+
+	plan.ID = state.ID
+	resp.Diagnostics.Append(r.read(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
 }
 
-// flattenRepository creates a map from a repository for easy storage on terraform.
-func flattenRepository(repo *models.Repository) map[string]interface{} {
-	var commands []int
+// reconcileCommands attaches and detaches commands, so exactly wanted are attached to repo.
+func (r *repositoryResource) reconcileCommands(ctx context.Context, repo *models.Repository, wanted []*models.Command) diag.Diagnostics {
+	existing := make(map[int]bool, len(repo.Commands))
 	for _, c := range repo.Commands {
-		commands = append(commands, c.ID)
+		existing[c.ID] = true
 	}
-	flatRepo := map[string]interface{}{
-		repoNameFieldName:     repo.Name,
-		repoURLFieldName:      repo.URL,
-		repoVCSFieldName:      repo.VCS,
-		repoAuthFieldName:     flattenAuth(repo),
-		repoCommandsFieldName: commands,
-		repoEventsFieldName:   repo.Events,
+	wantedIDs := make(map[int]bool, len(wanted))
+	var changes []relationshipChange
+	for _, c := range wanted {
+		wantedIDs[c.ID] = true
+		if !existing[c.ID] {
+			changes = append(changes, r.commandChange(ctx, repo.ID, c.ID, true))
+		}
 	}
-	if repo.GitLab != nil {
-		flatRepo[repoGitlabFieldName] = flattenGitlab(repo)
+	for _, c := range repo.Commands {
+		if !wantedIDs[c.ID] {
+			changes = append(changes, r.commandChange(ctx, repo.ID, c.ID, false))
+		}
 	}
-	return map[string]interface{}{}
+	return r.applyCommandChanges(changes)
 }
 
-// flattenAuth takes the auth part of a repository and creates a sub map for terraform schema.
-func flattenAuth(repo *models.Repository) []interface{} {
-	return []interface{}{
-		map[string]interface{}{
-			repoAuthSecretFieldName: repo.Auth.Secret,
-		},
-	}
+// commandChange returns the change attaching the command to the repository, or detaching it.
+func (r *repositoryResource) commandChange(ctx context.Context, repoID, commandID int, attach bool) relationshipChange {
+	return commandRelationship(ctx, commandID, repoID, "repository", attach,
+		r.client.CommandClient.AddRelationshipToRepository,
+		r.client.CommandClient.RemoveRelationshipToRepository,
+	)
 }
 
-// flattenGitlab takes the gitlab part of a repository and creates a sub map for terraform schema.
-func flattenGitlab(repo *models.Repository) []interface{} {
-	return []interface{}{
-		map[string]interface{}{
-			repoGitlabProjectIDFieldName: repo.GitLab.GetProjectID(),
-		},
+// applyCommandChanges applies changes to the commands of a repository, all of them or none.
+func (r *repositoryResource) applyCommandChanges(changes []relationshipChange) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, f := range applyRelationshipChanges(changes) {
+		r.client.Logger.Debug().Err(f.err).Msg(f.Error())
+		switch {
+		case f.reverting:
+			diags.AddError(
+				"Failed to roll back command relationship",
+				f.Error()+". The commands of the repository are partially changed, the next plan shows what's left to do.",
+			)
+		case f.change.attach:
+			diags.AddAttributeError(
+				path.Root(repoCommandsFieldName).AtSetValue(types.Int64Value(int64(f.change.command))),
+				"Failed to add command relationship",
+				f.Error(),
+			)
+		default:
+			diags.AddError("Failed to remove command relationship", f.Error())
+		}
 	}
+	return diags
 }
 
-// resourceRepositoryUpdate checks fields for differences and updates a repository if necessary.
-func resourceRepositoryUpdate(d *schema.ResourceData, m interface{}) error {
-	client := m.(*pkg.KrokClient)
-	rid, err := strconv.Atoi(d.Id())
-	if err != nil {
-		return err
+func (r *repositoryResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state repositoryResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
-	repo, err := client.RepositoryClient.Get(rid)
+	ctx, cancel := context.WithTimeout(ctx, state.Timeouts.delete(defaultDeleteTimeout))
+	defer cancel()
+	rid, err := strconv.Atoi(state.ID.ValueString())
 	if err != nil {
-		log.Println("Failed to find repository")
-		d.SetId("")
-		return err
-	}
-
-	if d.HasChange(repoNameFieldName) {
-		repo.Name = d.Get(repoNameFieldName).(string)
-	}
-
-	// If there was a change in the command list, either find a way to see
-	// the diff, or, look through all relationships of the repo and
-	// add what's missing and delete what has been deleted.
-	// compare repo.Commands with the IDs in commands.
-	if d.HasChange(repoCommandsFieldName) {
-		commands := d.Get(repoCommandsFieldName).([]interface{})
-		// checking any additions
-		for _, cid := range commands {
-			contains := false
-			for _, c := range repo.Commands {
-				if c.ID == cid {
-					contains = true
-					break
-				}
-			}
-			if !contains {
-				if err := client.CommandClient.AddRelationshipToRepository(cid.(int), repo.ID); err != nil {
-					log.Println("failed to add new command relationship")
-					return fmt.Errorf("failed to add command %d to repository %d: %w", cid.(int), repo.ID, err)
-				}
-			}
-		}
+		resp.Diagnostics.AddAttributeError(path.Root("id"), "Invalid repository ID", err.Error())
+		return
+	}
+	if err := r.client.RepositoryClient.Delete(ctx, rid); err != nil {
+		resp.Diagnostics.AddError("Failed to delete repository", err.Error())
+	}
+}
 
-		// checking any deletions
-		for _, c := range repo.Commands {
-			for _, cid := range commands {
-				contains := false
-				if c.ID == cid {
-					contains = true
-					break
-				}
-				if !contains {
-					if err := client.CommandClient.RemoveRelationshipToRepository(cid.(int), repo.ID); err != nil {
-						log.Println("failed to remove command relationship")
-						return fmt.Errorf("failed to remove command %d from repository %d: %w", cid, repo.ID, err)
-					}
-				}
-			}
+// ImportState imports a repository by its ID. The commands of an imported repository are managed by it,
+// so the following read fills them in.
+func (r *repositoryResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root(repoCommandsFieldName), types.SetValueMust(types.Int64Type, nil))...)
+}
+
+// expandRepository creates a Krok repository structure out of a Terraform model.
+func expandRepository(ctx context.Context, client *pkg.KrokClient, data repositoryResourceModel) (*models.Repository, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	repo := &models.Repository{
+		Name: data.Name.ValueString(),
+		URL:  data.URL.ValueString(),
+		VCS:  int(data.VCS.ValueInt64()),
+	}
+	switch {
+	case data.Auth != nil:
+		repo.Auth = &models.Auth{
+			Secret: data.Auth.Secret.ValueString(),
+		}
+	case !data.Secret.IsNull() && !data.Secret.IsUnknown():
+		repo.Auth = &models.Auth{
+			Secret: data.Secret.ValueString(),
 		}
 	}
-
-	if d.HasChange(repoEventsFieldName) {
-		var events []string
-		updatedEvents := d.Get(repoEventsFieldName).([]interface{})
-		for _, e := range updatedEvents {
-			events = append(events, e.(string))
+	if data.GitLab != nil {
+		repo.GitLab = &models.GitLab{
+			ProjectID: int(data.GitLab.ProjectID.ValueInt64()),
 		}
-		repo.Events = events
 	}
+	diags.Append(data.Events.ElementsAs(ctx, &repo.Events, false)...)
+	var commandIDs []int64
+	diags.Append(data.Commands.ElementsAs(ctx, &commandIDs, true)...)
+	if diags.HasError() {
+		return nil, diags
+	}
+	commands, d := expandCommands(ctx, client, commandIDs)
+	diags.Append(d...)
+	repo.Commands = commands
+	return repo, diags
+}
 
-	if res, err := client.RepositoryClient.Update(repo); err != nil {
-		log.Println("Failed to update repository")
-		return fmt.Errorf("failed to update repository: %w", err)
-	} else {
-		d.SetId(strconv.Itoa(res.ID))
+// expandCommands gathers all commands for which the IDs have been defined.
+func expandCommands(ctx context.Context, client *pkg.KrokClient, ids []int64) (commands []*models.Command, diags diag.Diagnostics) {
+	for _, id := range ids {
+		command, err := client.CommandClient.Get(ctx, int(id))
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root(repoCommandsFieldName).AtSetValue(types.Int64Value(id)),
+				fmt.Sprintf("failed to retrieve command with id %d", id),
+				err.Error(),
+			)
+			return nil, diags
+		}
+		commands = append(commands, command)
 	}
-	return resourceRepositoryRead(d, m)
+	return
 }
 
-func resourceRepositoryDelete(d *schema.ResourceData, m interface{}) error {
-	client := m.(*pkg.KrokClient)
-	rid, err := strconv.Atoi(d.Id())
-	if err != nil {
-		return err
+// flattenRepository stores a repository in data. Commands are only stored if they are managed by
+// this resource, and a secret the server doesn't return keeps what has been configured or generated.
+func flattenRepository(ctx context.Context, repo *models.Repository, data *repositoryResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	data.Name = types.StringValue(repo.Name)
+	data.URL = types.StringValue(repo.URL)
+	data.VCS = types.Int64Value(int64(repo.VCS))
+	data.UniqueURL = types.StringValue(repo.UniqueURL)
+
+	// without configured commands the relationships are left to krok_command_repository_attachment.
+	if !data.Commands.IsNull() {
+		commands := make([]int64, 0, len(repo.Commands))
+		for _, c := range repo.Commands {
+			commands = append(commands, int64(c.ID))
+		}
+		var d diag.Diagnostics
+		data.Commands, d = types.SetValueFrom(ctx, types.Int64Type, commands)
+		diags.Append(d...)
+	}
+	events, d := types.ListValueFrom(ctx, types.StringType, repo.Events)
+	diags.Append(d...)
+	data.Events = events
+
+	if repo.Auth != nil && repo.Auth.Secret != "" {
+		data.Secret = types.StringValue(repo.Auth.Secret)
+		// a generated secret isn't configured in auth.
+		if data.GenerateSecret == nil {
+			data.Auth = &repositoryAuthModel{Secret: types.StringValue(repo.Auth.Secret)}
+		}
+	}
+	if data.Secret.IsNull() && data.Auth != nil {
+		data.Secret = data.Auth.Secret
 	}
-	if err := client.RepositoryClient.Delete(rid); err != nil {
-		return err
+	data.SecretHash = hashSecret(data.Secret)
+	data.GitLab = nil
+	if repo.GitLab != nil && repo.GitLab.ProjectID != 0 {
+		data.GitLab = &repositoryGitlabModel{ProjectID: types.Int64Value(int64(repo.GitLab.GetProjectID()))}
 	}
-	d.SetId("") // called automatically, but added to be explicit
-	return nil
+	return diags
 }
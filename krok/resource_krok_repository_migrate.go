@@ -0,0 +1,178 @@
+package krok
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// repositoryResourceModelV0 maps the state of the SDK version of krok_repository, which stored
+// auth and gitlab as single element lists.
+type repositoryResourceModelV0 struct {
+	ID       types.String            `tfsdk:"id"`
+	Name     types.String            `tfsdk:"name"`
+	URL      types.String            `tfsdk:"url"`
+	VCS      types.Int64             `tfsdk:"vcs"`
+	Commands types.List              `tfsdk:"commands"`
+	Events   types.List              `tfsdk:"events"`
+	Auth     []repositoryAuthModel   `tfsdk:"auth"`
+	GitLab   []repositoryGitlabModel `tfsdk:"gitlab"`
+}
+
+// repositoryResourceModelV1 maps the state of krok_repository before commands became a set.
+type repositoryResourceModelV1 struct {
+	ID       types.String           `tfsdk:"id"`
+	Name     types.String           `tfsdk:"name"`
+	URL      types.String           `tfsdk:"url"`
+	VCS      types.Int64            `tfsdk:"vcs"`
+	Commands types.List             `tfsdk:"commands"`
+	Events   types.List             `tfsdk:"events"`
+	Auth     *repositoryAuthModel   `tfsdk:"auth"`
+	GitLab   *repositoryGitlabModel `tfsdk:"gitlab"`
+}
+
+// UpgradeState migrates every earlier state version straight to the current schema.
+func (r *repositoryResource) UpgradeState(_ context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		0: {
+			PriorSchema: &schema.Schema{
+				Attributes: map[string]schema.Attribute{
+					"id":                  schema.StringAttribute{Computed: true},
+					repoNameFieldName:     schema.StringAttribute{Required: true},
+					repoURLFieldName:      schema.StringAttribute{Required: true},
+					repoVCSFieldName:      schema.Int64Attribute{Required: true},
+					repoCommandsFieldName: schema.ListAttribute{Optional: true, ElementType: types.Int64Type},
+					repoEventsFieldName:   schema.ListAttribute{Required: true, ElementType: types.StringType},
+				},
+				Blocks: map[string]schema.Block{
+					repoAuthFieldName: schema.ListNestedBlock{
+						NestedObject: schema.NestedBlockObject{
+							Attributes: map[string]schema.Attribute{
+								repoAuthSecretFieldName: schema.StringAttribute{Required: true},
+							},
+						},
+					},
+					repoGitlabFieldName: schema.ListNestedBlock{
+						NestedObject: schema.NestedBlockObject{
+							Attributes: map[string]schema.Attribute{
+								repoGitlabProjectIDFieldName: schema.Int64Attribute{Required: true},
+							},
+						},
+					},
+				},
+			},
+			StateUpgrader: r.upgradeStateV0,
+		},
+		1: {
+			PriorSchema: &schema.Schema{
+				Attributes: map[string]schema.Attribute{
+					"id":                  schema.StringAttribute{Computed: true},
+					repoNameFieldName:     schema.StringAttribute{Required: true},
+					repoURLFieldName:      schema.StringAttribute{Required: true},
+					repoVCSFieldName:      schema.Int64Attribute{Required: true},
+					repoCommandsFieldName: schema.ListAttribute{Optional: true, ElementType: types.Int64Type},
+					repoEventsFieldName:   schema.ListAttribute{Required: true, ElementType: types.StringType},
+					repoAuthFieldName: schema.SingleNestedAttribute{
+						Required: true,
+						Attributes: map[string]schema.Attribute{
+							repoAuthSecretFieldName: schema.StringAttribute{Required: true},
+						},
+					},
+					repoGitlabFieldName: schema.SingleNestedAttribute{
+						Optional: true,
+						Attributes: map[string]schema.Attribute{
+							repoGitlabProjectIDFieldName: schema.Int64Attribute{Required: true},
+						},
+					},
+				},
+			},
+			StateUpgrader: r.upgradeStateV1,
+		},
+	}
+}
+
+// upgradeStateV0 turns the auth and gitlab lists into nested attributes.
+func (r *repositoryResource) upgradeStateV0(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+	var prior repositoryResourceModelV0
+	resp.Diagnostics.Append(req.State.Get(ctx, &prior)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	v1 := repositoryResourceModelV1{
+		ID:       prior.ID,
+		Name:     prior.Name,
+		URL:      prior.URL,
+		VCS:      prior.VCS,
+		Commands: prior.Commands,
+		Events:   prior.Events,
+	}
+	if len(prior.Auth) > 0 {
+		v1.Auth = &prior.Auth[0]
+	}
+	if len(prior.GitLab) > 0 {
+		v1.GitLab = &prior.GitLab[0]
+	}
+	r.upgradeFromV1(ctx, v1, resp)
+}
+
+func (r *repositoryResource) upgradeStateV1(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+	var prior repositoryResourceModelV1
+	resp.Diagnostics.Append(req.State.Get(ctx, &prior)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	r.upgradeFromV1(ctx, prior, resp)
+}
+
+// upgradeFromV1 turns the command list into a set and fetches unique_url, which wasn't stored
+// before. Without a configured provider unique_url is left for the next refresh to fill in.
+func (r *repositoryResource) upgradeFromV1(ctx context.Context, prior repositoryResourceModelV1, resp *resource.UpgradeStateResponse) {
+	upgraded := repositoryResourceModel{
+		ID:        prior.ID,
+		Name:      prior.Name,
+		URL:       prior.URL,
+		VCS:       prior.VCS,
+		Commands:  types.SetNull(types.Int64Type),
+		Events:    prior.Events,
+		Auth:      prior.Auth,
+		GitLab:    prior.GitLab,
+		UniqueURL: types.StringNull(),
+	}
+
+	var commands []int64
+	resp.Diagnostics.Append(prior.Commands.ElementsAs(ctx, &commands, true)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	// the SDK stored an empty list for commands which weren't configured, and lists may contain duplicates.
+	if len(commands) > 0 {
+		seen := make(map[int64]bool, len(commands))
+		unique := make([]int64, 0, len(commands))
+		for _, c := range commands {
+			if !seen[c] {
+				seen[c] = true
+				unique = append(unique, c)
+			}
+		}
+		set, d := types.SetValueFrom(ctx, types.Int64Type, unique)
+		resp.Diagnostics.Append(d...)
+		upgraded.Commands = set
+	}
+
+	if r.client != nil {
+		if rid, err := strconv.Atoi(prior.ID.ValueString()); err == nil {
+			if repo, err := r.client.RepositoryClient.Get(ctx, rid); err != nil {
+				r.client.Logger.Debug().Err(err).Int("repository", rid).Msg("Failed to fetch unique url while upgrading state.")
+			} else {
+				upgraded.UniqueURL = types.StringValue(repo.UniqueURL)
+			}
+		}
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, upgraded)...)
+}
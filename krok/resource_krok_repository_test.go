@@ -1 +1,387 @@
 package krok
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/krok/pkg/models"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+	"github.com/krok-o/terraform-provider-krok/pkg/clients/fake"
+)
+
+// upgradeRepositoryState feeds raw state JSON of the given version through the matching upgrader.
+func upgradeRepositoryState(t *testing.T, r *repositoryResource, version int64, raw string) repositoryResourceModel {
+	t.Helper()
+	ctx := context.Background()
+	upgrader, ok := r.UpgradeState(ctx)[version]
+	if !ok {
+		t.Fatalf("no upgrader for version %d", version)
+	}
+	prior, err := (&tfprotov6.RawState{JSON: []byte(raw)}).Unmarshal(upgrader.PriorSchema.Type().TerraformType(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var current resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &current)
+	req := resource.UpgradeStateRequest{
+		State: &tfsdk.State{Raw: prior, Schema: *upgrader.PriorSchema},
+	}
+	resp := resource.UpgradeStateResponse{
+		State: tfsdk.State{Raw: tftypes.NewValue(current.Schema.Type().TerraformType(ctx), nil), Schema: current.Schema},
+	}
+	upgrader.StateUpgrader(ctx, req, &resp)
+	for _, d := range resp.Diagnostics {
+		t.Errorf("%s: %s", d.Summary(), d.Detail())
+	}
+	var upgraded repositoryResourceModel
+	if diags := resp.State.Get(ctx, &upgraded); diags.HasError() {
+		t.Fatal(diags)
+	}
+	return upgraded
+}
+
+func TestRepositoryStateUpgradeV0(t *testing.T) {
+	upgraded := upgradeRepositoryState(t, &repositoryResource{}, 0, `{
+		"id": "3",
+		"name": "test",
+		"url": "https://github.com/krok-o/test",
+		"vcs": 1,
+		"commands": [2, 1, 2],
+		"events": ["push", "ping"],
+		"auth": [{"secret": "secret"}],
+		"gitlab": []
+	}`)
+	if upgraded.ID.ValueString() != "3" || upgraded.Name.ValueString() != "test" || upgraded.VCS.ValueInt64() != 1 {
+		t.Errorf("scalar attributes were not kept: %+v", upgraded)
+	}
+	if upgraded.Auth == nil || upgraded.Auth.Secret.ValueString() != "secret" {
+		t.Errorf("auth was not moved to a nested attribute: %+v", upgraded.Auth)
+	}
+	if upgraded.GitLab != nil {
+		t.Errorf("an empty gitlab list should be null, got %+v", upgraded.GitLab)
+	}
+	var commands []int64
+	upgraded.Commands.ElementsAs(context.Background(), &commands, false)
+	if len(commands) != 2 {
+		t.Errorf("commands should be a set of the two distinct IDs, got %v", commands)
+	}
+	if len(upgraded.Events.Elements()) != 2 {
+		t.Errorf("events were not kept: %v", upgraded.Events)
+	}
+	if !upgraded.UniqueURL.IsNull() {
+		t.Errorf("unique_url can't be known without a client, got %s", upgraded.UniqueURL)
+	}
+}
+
+func TestRepositoryStateUpgradeV0WithoutCommands(t *testing.T) {
+	upgraded := upgradeRepositoryState(t, &repositoryResource{}, 0, `{
+		"id": "3",
+		"name": "test",
+		"url": "https://gitlab.com/krok-o/test",
+		"vcs": 2,
+		"commands": [],
+		"events": ["Push Hook"],
+		"auth": [{"secret": "secret"}],
+		"gitlab": [{"project_id": 10}]
+	}`)
+	if !upgraded.Commands.IsNull() {
+		t.Errorf("commands which were never configured should be null, got %v", upgraded.Commands)
+	}
+	if upgraded.GitLab == nil || upgraded.GitLab.ProjectID.ValueInt64() != 10 {
+		t.Errorf("gitlab was not moved to a nested attribute: %+v", upgraded.GitLab)
+	}
+}
+
+func TestRepositoryStateUpgradeV1(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	server.AddRepository(&models.Repository{ID: 3, Name: "test", UniqueURL: "https://krok.app/hooks/3/1/callback"})
+	client := pkg.NewKrokClient(pkg.Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: fake.APIKeySecret,
+	}, zerolog.Nop())
+
+	upgraded := upgradeRepositoryState(t, &repositoryResource{client: client}, 1, `{
+		"id": "3",
+		"name": "test",
+		"url": "https://github.com/krok-o/test",
+		"vcs": 1,
+		"commands": [1],
+		"events": ["push"],
+		"auth": {"secret": "secret"},
+		"gitlab": null
+	}`)
+	if upgraded.UniqueURL.ValueString() != "https://krok.app/hooks/3/1/callback" {
+		t.Errorf("unique_url was not fetched, got %s", upgraded.UniqueURL)
+	}
+	if len(upgraded.Commands.Elements()) != 1 {
+		t.Errorf("commands were not kept: %v", upgraded.Commands)
+	}
+	if upgraded.Auth == nil || upgraded.Auth.Secret.ValueString() != "secret" {
+		t.Errorf("auth was not kept: %+v", upgraded.Auth)
+	}
+}
+
+func TestValidateCommands(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	github := models.SupportedPlatforms[models.GITHUB]
+	server.AddCommand(&models.Command{ID: 1, Name: "enabled", Enabled: true, Platforms: []models.Platform{github}})
+	server.AddCommand(&models.Command{ID: 2, Name: "disabled", Platforms: []models.Platform{github}})
+	server.AddCommand(&models.Command{ID: 3, Name: "gitlab", Enabled: true, Platforms: []models.Platform{models.SupportedPlatforms[models.GITLAB]}})
+	r := &repositoryResource{client: pkg.NewKrokClient(pkg.Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: fake.APIKeySecret,
+	}, zerolog.Nop())}
+
+	commands := func(values ...attr.Value) types.Set {
+		return types.SetValueMust(types.Int64Type, values)
+	}
+	for _, tc := range []struct {
+		name     string
+		commands types.Set
+		// warnings and errors are the expected summaries.
+		warnings, errors []string
+	}{
+		{name: "enabled for the platform", commands: commands(types.Int64Value(1))},
+		{name: "disabled", commands: commands(types.Int64Value(2)), warnings: []string{"Command is disabled"}},
+		{
+			name:     "other platform",
+			commands: commands(types.Int64Value(3)),
+			warnings: []string{"Command doesn't support the repository's platform"},
+		},
+		{name: "created in the same plan", commands: commands(types.Int64Unknown())},
+		{
+			name:     "missing",
+			commands: commands(types.Int64Value(1), types.Int64Value(4)),
+			errors:   []string{"failed to retrieve command with id 4"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var diags diag.Diagnostics
+			r.validateCommands(context.Background(), types.Int64Value(models.GITHUB), tc.commands, &diags)
+			var warnings, errors []string
+			for _, d := range diags.Warnings() {
+				warnings = append(warnings, d.Summary())
+			}
+			for _, d := range diags.Errors() {
+				errors = append(errors, d.Summary())
+			}
+			if strings.Join(warnings, "\n") != strings.Join(tc.warnings, "\n") {
+				t.Errorf("expected warnings %q, got %q", tc.warnings, warnings)
+			}
+			if strings.Join(errors, "\n") != strings.Join(tc.errors, "\n") {
+				t.Errorf("expected errors %q, got %q", tc.errors, errors)
+			}
+		})
+	}
+}
+
+func TestFlattenRepositorySecretHash(t *testing.T) {
+	ctx := context.Background()
+	// Krok doesn't return the secret, so the hash follows the configured one.
+	data := repositoryResourceModel{
+		Commands: types.SetNull(types.Int64Type),
+		Auth:     &repositoryAuthModel{Secret: types.StringValue("rotated")},
+	}
+	if diags := flattenRepository(ctx, &models.Repository{ID: 1, Name: "test"}, &data); diags.HasError() {
+		t.Fatal(diags)
+	}
+	if want := "f42546d5ecdd452509808b2d6d0413b5a738c70a793b99ccf8ed6f423aac83d3"; data.SecretHash.ValueString() != want {
+		t.Errorf("expected the hash of the configured secret %s, got %s", want, data.SecretHash)
+	}
+	if data.SecretHash.Equal(hashSecret(types.StringValue("secret"))) {
+		t.Error("a rotated secret should change the hash")
+	}
+
+	data.Auth = nil
+	data.Secret = types.StringNull()
+	data.GenerateSecret = &repositoryGenerateSecretModel{}
+	if diags := flattenRepository(ctx, &models.Repository{ID: 1, Auth: &models.Auth{Secret: "generated"}}, &data); diags.HasError() {
+		t.Fatal(diags)
+	}
+	if !data.SecretHash.Equal(hashSecret(types.StringValue("generated"))) {
+		t.Errorf("expected the hash of the returned secret, got %s", data.SecretHash)
+	}
+}
+
+func TestPlanSecret(t *testing.T) {
+	ctx := context.Background()
+	r := &repositoryResource{}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	repository := func(auth string, generate *repositoryGenerateSecretModel, version int64, secret string) *repositoryResourceModel {
+		data := &repositoryResourceModel{
+			ID:             types.StringValue("1"),
+			Name:           types.StringValue("test"),
+			URL:            types.StringValue("https://github.com/krok-o/test"),
+			VCS:            types.Int64Value(models.GITHUB),
+			Commands:       types.SetNull(types.Int64Type),
+			Events:         types.ListValueMust(types.StringType, []attr.Value{types.StringValue("push")}),
+			UniqueURL:      types.StringNull(),
+			GenerateSecret: generate,
+			SecretVersion:  types.Int64Null(),
+			Secret:         types.StringValue(secret),
+		}
+		data.SecretHash = hashSecret(data.Secret)
+		if auth != "" {
+			data.Auth = &repositoryAuthModel{Secret: types.StringValue(auth)}
+		}
+		if version != 0 {
+			data.SecretVersion = types.Int64Value(version)
+		}
+		return data
+	}
+	options := func(length int64) *repositoryGenerateSecretModel {
+		return &repositoryGenerateSecretModel{Length: types.Int64Value(length), Charset: types.StringNull()}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		state *repositoryResourceModel
+		plan  *repositoryResourceModel
+		// want is the planned secret, empty if a new one is generated.
+		want string
+	}{
+		{name: "new with auth", plan: repository("configured", nil, 0, ""), want: "configured"},
+		{name: "new generated", plan: repository("", options(32), 0, "")},
+		{name: "rotated auth", state: repository("old", nil, 0, "old"), plan: repository("new", nil, 0, "old"), want: "new"},
+		{name: "generated kept", state: repository("", options(32), 1, "generated"), plan: repository("", options(32), 1, "generated"), want: "generated"},
+		{name: "switched from auth", state: repository("configured", nil, 0, "configured"), plan: repository("", options(32), 0, "configured")},
+		{name: "secret_version bumped", state: repository("", options(32), 1, "generated"), plan: repository("", options(32), 2, "generated")},
+		{name: "secret_version set", state: repository("", options(32), 0, "generated"), plan: repository("", options(32), 1, "generated")},
+		{name: "options changed", state: repository("", options(32), 1, "generated"), plan: repository("", options(40), 1, "generated")},
+		{name: "switched to auth", state: repository("", options(32), 1, "generated"), plan: repository("configured", nil, 0, "generated"), want: "configured"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			state := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+			if tc.state != nil {
+				if diags := state.Set(ctx, tc.state); diags.HasError() {
+					t.Fatal(diags)
+				}
+			}
+			plan := tfsdk.Plan{Schema: schemaResp.Schema}
+			if diags := plan.Set(ctx, tc.plan); diags.HasError() {
+				t.Fatal(diags)
+			}
+			resp := resource.ModifyPlanResponse{Plan: plan}
+			r.planSecret(ctx, resource.ModifyPlanRequest{State: state, Plan: plan}, &resp)
+			for _, d := range resp.Diagnostics {
+				t.Fatalf("%s: %s", d.Summary(), d.Detail())
+			}
+			var planned repositoryResourceModel
+			if diags := resp.Plan.Get(ctx, &planned); diags.HasError() {
+				t.Fatal(diags)
+			}
+			if tc.want == "" {
+				if !planned.Secret.IsUnknown() || !planned.SecretHash.IsUnknown() {
+					t.Errorf("expected a new secret to be generated, got %s with hash %s", planned.Secret, planned.SecretHash)
+				}
+				return
+			}
+			if planned.Secret.ValueString() != tc.want {
+				t.Errorf("expected secret %q, got %s", tc.want, planned.Secret)
+			}
+			if !planned.SecretHash.Equal(hashSecret(types.StringValue(tc.want))) {
+				t.Errorf("expected the hash of %q, got %s", tc.want, planned.SecretHash)
+			}
+		})
+	}
+}
+
+// importRepository imports the repository with the given ID like Terraform does, with ImportState followed by Read.
+func importRepository(t *testing.T, client *pkg.KrokClient, id string) repositoryResourceModel {
+	t.Helper()
+	ctx := context.Background()
+	r := &repositoryResource{client: client}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	imported := resource.ImportStateResponse{
+		State: tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)},
+	}
+	r.ImportState(ctx, resource.ImportStateRequest{ID: id}, &imported)
+	read := resource.ReadResponse{State: imported.State}
+	if !imported.Diagnostics.HasError() {
+		r.Read(ctx, resource.ReadRequest{State: imported.State}, &read)
+	}
+	for _, d := range append(imported.Diagnostics, read.Diagnostics...) {
+		t.Fatalf("%s: %s", d.Summary(), d.Detail())
+	}
+	var data repositoryResourceModel
+	if diags := read.State.Get(ctx, &data); diags.HasError() {
+		t.Fatal(diags)
+	}
+	return data
+}
+
+func TestRepositoryImport(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	server.AddRepository(&models.Repository{
+		ID:       3,
+		Name:     "test",
+		URL:      "https://github.com/krok-o/test",
+		VCS:      models.GITHUB,
+		Events:   []string{"push"},
+		Auth:     &models.Auth{Secret: "secret"},
+		Commands: []*models.Command{{ID: 1}, {ID: 2}},
+	})
+	client := pkg.NewKrokClient(pkg.Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: fake.APIKeySecret,
+	}, zerolog.Nop())
+
+	imported := importRepository(t, client, "3")
+	var commands []int64
+	imported.Commands.ElementsAs(context.Background(), &commands, false)
+	if len(commands) != 2 {
+		t.Errorf("the commands of an imported repository should be managed by it, got %v", imported.Commands)
+	}
+	if imported.Auth == nil || imported.Auth.Secret.ValueString() != "secret" {
+		t.Errorf("auth was not imported: %+v", imported.Auth)
+	}
+}
+
+func TestRepositoryReadRemovesDeletedRepository(t *testing.T) {
+	ctx := context.Background()
+	server := fake.NewServer()
+	defer server.Close()
+	r := &repositoryResource{client: pkg.NewKrokClient(pkg.Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: fake.APIKeySecret,
+	}, zerolog.Nop())}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	imported := resource.ImportStateResponse{
+		State: tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)},
+	}
+	r.ImportState(ctx, resource.ImportStateRequest{ID: "3"}, &imported)
+
+	read := resource.ReadResponse{State: imported.State}
+	r.Read(ctx, resource.ReadRequest{State: imported.State}, &read)
+	for _, d := range append(imported.Diagnostics, read.Diagnostics...) {
+		t.Fatalf("%s: %s", d.Summary(), d.Detail())
+	}
+	if !read.State.Raw.IsNull() {
+		t.Fatal("expected a repository which no longer exists to be removed from the state")
+	}
+}
@@ -1,11 +1,12 @@
 package krok
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"strconv"
 
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 
 	"github.com/krok-o/krok/pkg/models"
 
@@ -21,10 +22,19 @@ const (
 
 func resourceCommandSettings() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceCommandSettingsCreate,
-		Read:   resourceCommandSettingRead,
-		Update: resourceCommandSettingUpdate,
-		Delete: resourceCommandSettingDelete,
+		CreateContext: resourceCommandSettingsCreate,
+		ReadContext:   resourceCommandSettingRead,
+		UpdateContext: resourceCommandSettingUpdate,
+		DeleteContext: resourceCommandSettingDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(defaultCreateTimeout),
+			Read:   schema.DefaultTimeout(defaultReadTimeout),
+			Update: schema.DefaultTimeout(defaultUpdateTimeout),
+			Delete: schema.DefaultTimeout(defaultDeleteTimeout),
+		},
 
 		Schema: map[string]*schema.Schema{
 			commandSettingsKeyFieldName: {
@@ -32,8 +42,9 @@ func resourceCommandSettings() *schema.Resource {
 				Required: true,
 			},
 			commandSettingsValueFieldName: {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:      schema.TypeString,
+				Required:  true,
+				Sensitive: true,
 			},
 			commandSettingsCommandIDFieldName: {
 				Type:     schema.TypeInt,
@@ -48,101 +59,96 @@ func resourceCommandSettings() *schema.Resource {
 }
 
 // resourceCommandSettingsCreate creates a Krok platform.
-func resourceCommandSettingsCreate(d *schema.ResourceData, m interface{}) error {
+func resourceCommandSettingsCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*pkg.KrokClient)
-	expandedSetting, err := expandCommandSettingResource(d)
-	if err != nil {
-		return err
+	expandedSetting, diags := expandCommandSettingResource(d)
+	if diags.HasError() {
+		return diags
 	}
-	setting, err := client.SettingsClient.Create(expandedSetting)
+	setting, err := client.SettingsClient.Create(ctx, expandedSetting)
 	if err != nil {
-		log.Println("Failed to create setting.")
-		return fmt.Errorf("failed to create setting: %w", err)
+		client.Logger.Debug().Err(err).Msg("Failed to create setting.")
+		return diag.FromErr(fmt.Errorf("failed to create setting: %w", err))
 	}
 	d.SetId(strconv.Itoa(setting.ID))
-	return resourceCommandRead(d, m)
+	return resourceCommandSettingRead(ctx, d, m)
 }
 
 // expandCommandSettingResource creates a Krok command setting structure out of a Terraform schema model.
-func expandCommandSettingResource(d *schema.ResourceData) (*models.CommandSetting, error) {
+func expandCommandSettingResource(d *schema.ResourceData) (*models.CommandSetting, diag.Diagnostics) {
 	var (
 		key, value string
-		inVault    bool
 		commandID  int
 	)
 	if v, ok := d.GetOk(commandSettingsKeyFieldName); ok {
 		key = v.(string)
 	} else {
-		return nil, fmt.Errorf("unable to find parse field %s", commandSettingsKeyFieldName)
+		return nil, missingFieldDiag(commandSettingsKeyFieldName)
 	}
 	if v, ok := d.GetOk(commandSettingsValueFieldName); ok {
 		value = v.(string)
 	} else {
-		return nil, fmt.Errorf("unable to find parse field %s", commandSettingsValueFieldName)
-	}
-	if v, ok := d.GetOk(commandSettingsInVaultFieldName); ok {
-		inVault = v.(bool)
-	} else {
-		return nil, fmt.Errorf("unable to find parse field %s", commandSettingsInVaultFieldName)
+		return nil, missingFieldDiag(commandSettingsValueFieldName)
 	}
 	if v, ok := d.GetOk(commandSettingsCommandIDFieldName); ok {
 		commandID = v.(int)
 	} else {
-		return nil, fmt.Errorf("unable to find parse field %s", commandSettingsCommandIDFieldName)
+		return nil, missingFieldDiag(commandSettingsCommandIDFieldName)
 	}
 	return &models.CommandSetting{
 		CommandID: commandID,
 		Key:       key,
 		Value:     value,
-		InVault:   inVault,
+		// GetOk can't tell false from unset, but the field is required.
+		InVault: d.Get(commandSettingsInVaultFieldName).(bool),
 	}, nil
 }
 
 // resourceCommandSettingUpdate updates command setting information from terraform stores.
-func resourceCommandSettingUpdate(d *schema.ResourceData, m interface{}) error {
+func resourceCommandSettingUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*pkg.KrokClient)
 	cid, err := strconv.Atoi(d.Id())
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	setting, err := client.SettingsClient.Get(cid)
+	setting, err := client.SettingsClient.Get(ctx, cid)
 	if err != nil {
-		log.Println("Failed to find command setting")
+		client.Logger.Debug().Err(err).Msg("Failed to find command setting")
 		d.SetId("")
-		return err
+		return diag.FromErr(err)
 	}
 
 	if d.HasChange(commandSettingsValueFieldName) {
 		setting.Value = d.Get(commandSettingsValueFieldName).(string)
 	}
 
-	if err := client.SettingsClient.Update(setting); err != nil {
-		log.Println("Failed to update command setting")
-		return fmt.Errorf("failed to update command setting: %w", err)
+	if err := client.SettingsClient.Update(ctx, setting); err != nil {
+		client.Logger.Debug().Err(err).Msg("Failed to update command setting")
+		return diag.FromErr(fmt.Errorf("failed to update command setting: %w", err))
 	} else {
 		d.SetId(strconv.Itoa(setting.ID))
 	}
-	return resourceCommandSettingRead(d, m)
+	return resourceCommandSettingRead(ctx, d, m)
 }
 
 // resourceCommandSettingRead retrieves command setting information from terraform stores.
-func resourceCommandSettingRead(d *schema.ResourceData, m interface{}) error {
+func resourceCommandSettingRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*pkg.KrokClient)
 	sid, err := strconv.Atoi(d.Id())
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	setting, err := client.SettingsClient.Get(sid)
+	setting, err := client.SettingsClient.Get(ctx, sid)
 	if err != nil {
-		log.Println("Failed to find command setting")
+		client.Logger.Debug().Err(err).Msg("Failed to find command setting")
 		d.SetId("")
-		return err
+		return diag.FromErr(err)
 	}
 
 	for k, v := range flattenCommandSetting(setting) {
 		if err := d.Set(k, v); err != nil {
 			d.SetId("")
-			return err
+			return diag.FromErr(err)
 		}
 	}
 	return nil
@@ -159,14 +165,14 @@ func flattenCommandSetting(setting *models.CommandSetting) map[string]interface{
 	return flatCommandSetting
 }
 
-func resourceCommandSettingDelete(d *schema.ResourceData, m interface{}) error {
+func resourceCommandSettingDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*pkg.KrokClient)
 	sid, err := strconv.Atoi(d.Id())
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	if err := client.SettingsClient.Delete(sid); err != nil {
-		return err
+	if err := client.SettingsClient.Delete(ctx, sid); err != nil {
+		return diag.FromErr(err)
 	}
 	d.SetId("") // called automatically, but added to be explicit
 	return nil
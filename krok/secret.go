@@ -0,0 +1,65 @@
+package krok
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const (
+	// defaultSecretLength is the length of generated webhook secrets.
+	defaultSecretLength = 32
+	minSecretLength     = 16
+	maxSecretLength     = 256
+	// defaultSecretCharset is used for generated webhook secrets, which most platforms accept without escaping.
+	defaultSecretCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// generateSecret returns a cryptographically random secret of length characters from charset.
+func generateSecret(length int, charset string) (string, error) {
+	if err := validateSecretOptions(length, charset); err != nil {
+		return "", err
+	}
+	chars := []rune(charset)
+	max := big.NewInt(int64(len(chars)))
+	secret := make([]rune, length)
+	for i := range secret {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate secret: %w", err)
+		}
+		secret[i] = chars[n.Int64()]
+	}
+	return string(secret), nil
+}
+
+// validateSecretOptions checks the length and charset of a generated secret.
+func validateSecretOptions(length int, charset string) error {
+	if length < minSecretLength || length > maxSecretLength {
+		return fmt.Errorf("length must be between %d and %d, got %d", minSecretLength, maxSecretLength, length)
+	}
+	seen := make(map[rune]bool)
+	for _, c := range charset {
+		if seen[c] {
+			return fmt.Errorf("charset contains %q more than once", c)
+		}
+		seen[c] = true
+	}
+	if len(seen) < 2 {
+		return fmt.Errorf("charset must contain at least two characters")
+	}
+	return nil
+}
+
+// hashSecret returns the hex encoded SHA-256 sum of secret, or secret itself if it's null or unknown.
+func hashSecret(secret types.String) types.String {
+	if secret.IsNull() || secret.IsUnknown() {
+		return secret
+	}
+	sum := sha256.Sum256([]byte(secret.ValueString()))
+	return types.StringValue(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,42 @@
+package krok
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := generateSecret(40, "ab€")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len([]rune(secret)); n != 40 {
+		t.Fatalf("expected 40 characters, got %d", n)
+	}
+	if s := strings.Trim(secret, "ab€"); s != "" {
+		t.Fatalf("expected only characters of the charset, got %q", s)
+	}
+	other, err := generateSecret(40, "ab€")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == other {
+		t.Fatal("expected two generated secrets to differ")
+	}
+}
+
+func TestGenerateSecretRejectsInvalidOptions(t *testing.T) {
+	for _, tc := range []struct {
+		length  int
+		charset string
+	}{
+		{length: minSecretLength - 1, charset: defaultSecretCharset},
+		{length: maxSecretLength + 1, charset: defaultSecretCharset},
+		{length: defaultSecretLength, charset: "a"},
+		{length: defaultSecretLength, charset: "abca"},
+	} {
+		if _, err := generateSecret(tc.length, tc.charset); err == nil {
+			t.Errorf("expected length %d and charset %q to be rejected", tc.length, tc.charset)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package krok
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Default timeouts of the operations of resources. Each request of an operation is limited by what's
+// left of the operation's timeout, instead of clients.DefaultTimeout.
+const (
+	defaultCreateTimeout = 5 * time.Minute
+	defaultReadTimeout   = time.Minute
+	defaultUpdateTimeout = 5 * time.Minute
+	defaultDeleteTimeout = 5 * time.Minute
+	// defaultUploadTimeout is used to create command archives, which can be large.
+	defaultUploadTimeout = 20 * time.Minute
+)
+
+const timeoutsFieldName = "timeouts"
+
+// timeoutsModel maps the timeouts block of the framework resources, which mirrors the one the SDK adds
+// to resources with Timeouts.
+type timeoutsModel struct {
+	Create types.String `tfsdk:"create"`
+	Read   types.String `tfsdk:"read"`
+	Update types.String `tfsdk:"update"`
+	Delete types.String `tfsdk:"delete"`
+}
+
+// timeoutsBlock returns the schema of the timeouts block. The defaults are only used in descriptions.
+func timeoutsBlock(create, read, update, del time.Duration) schema.SingleNestedBlock {
+	attribute := func(op string, def time.Duration) schema.StringAttribute {
+		return schema.StringAttribute{
+			Optional:    true,
+			Validators:  []validator.String{durationValidator{}},
+			Description: fmt.Sprintf("Timeout to %s the resource, like 30s or 10m. Defaults to %s.", op, def),
+		}
+	}
+	return schema.SingleNestedBlock{
+		Description: "Timeouts of the operations on the resource.",
+		Attributes: map[string]schema.Attribute{
+			"create": attribute("create", create),
+			"read":   attribute("read", read),
+			"update": attribute("update", update),
+			"delete": attribute("delete", del),
+		},
+	}
+}
+
+// create returns the timeout for creating the resource, or def if it isn't configured.
+func (t *timeoutsModel) create(def time.Duration) time.Duration {
+	if t == nil {
+		return def
+	}
+	return parseTimeout(t.Create, def)
+}
+
+// read returns the timeout for reading the resource, or def if it isn't configured.
+func (t *timeoutsModel) read(def time.Duration) time.Duration {
+	if t == nil {
+		return def
+	}
+	return parseTimeout(t.Read, def)
+}
+
+// update returns the timeout for updating the resource, or def if it isn't configured.
+func (t *timeoutsModel) update(def time.Duration) time.Duration {
+	if t == nil {
+		return def
+	}
+	return parseTimeout(t.Update, def)
+}
+
+// delete returns the timeout for deleting the resource, or def if it isn't configured.
+func (t *timeoutsModel) delete(def time.Duration) time.Duration {
+	if t == nil {
+		return def
+	}
+	return parseTimeout(t.Delete, def)
+}
+
+// parseTimeout returns the duration in value, or def if it isn't set. Invalid durations are rejected by
+// durationValidator.
+func parseTimeout(value types.String, def time.Duration) time.Duration {
+	if value.IsNull() || value.IsUnknown() {
+		return def
+	}
+	d, err := time.ParseDuration(value.ValueString())
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+// durationValidator checks that a string is a positive duration like 30s or 10m.
+type durationValidator struct{}
+
+func (v durationValidator) Description(context.Context) string {
+	return "value must be a positive duration like 30s or 10m"
+}
+
+func (v durationValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v durationValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	d, err := time.ParseDuration(req.ConfigValue.ValueString())
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("%s isn't positive", req.ConfigValue.ValueString())
+	}
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid timeout", fmt.Sprintf("%s: %s", v.Description(ctx), err))
+	}
+}
@@ -0,0 +1,49 @@
+package krok
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/robfig/cron/v3"
+)
+
+// scheduleParser accepts standard five field cron expressions and descriptors like @hourly or @every 1h.
+var scheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// validateSchedule checks that a schedule is a valid cron expression or descriptor.
+func validateSchedule(i interface{}, k string) ([]string, []error) {
+	schedule, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+	if _, err := scheduleParser.Parse(schedule); err != nil {
+		return nil, []error{fmt.Errorf("%s is not a valid cron expression: %w", k, err)}
+	}
+	return nil, nil
+}
+
+// validateImage checks that an image is a valid OCI image reference like krokhook/slack-notification:v0.0.1.
+func validateImage(i interface{}, k string) ([]string, []error) {
+	image, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+	if _, err := reference.ParseNormalizedNamed(image); err != nil {
+		return nil, []error{fmt.Errorf("%s is not a valid image reference: %w", k, err)}
+	}
+	return nil, nil
+}
+
+// nextRunTime returns the first time after now at which schedule fires, formatted as RFC 3339.
+// An empty or invalid schedule has no next run time.
+func nextRunTime(schedule string, now time.Time) string {
+	if schedule == "" {
+		return ""
+	}
+	s, err := scheduleParser.Parse(schedule)
+	if err != nil {
+		return ""
+	}
+	return s.Next(now).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,72 @@
+package krok
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	for _, tc := range []struct {
+		schedule string
+		valid    bool
+	}{
+		{"*/5 * * * *", true},
+		{"0 3 * * MON-FRI", true},
+		{"@hourly", true},
+		{"@every 1h30m", true},
+		{"", false},
+		{"* * * *", false},
+		{"0 0 * * * *", false},
+		{"61 * * * *", false},
+		{"@sometimes", false},
+	} {
+		_, errs := validateSchedule(tc.schedule, "schedule")
+		if valid := len(errs) == 0; valid != tc.valid {
+			t.Errorf("schedule %q: expected valid %t, got errors %v", tc.schedule, tc.valid, errs)
+		}
+	}
+	if _, errs := validateSchedule(1, "schedule"); len(errs) == 0 {
+		t.Error("expected a non-string schedule to be rejected")
+	}
+}
+
+func TestValidateImage(t *testing.T) {
+	for _, tc := range []struct {
+		image string
+		valid bool
+	}{
+		{"krokhook/slack-notification:v0.0.1", true},
+		{"alpine", true},
+		{"registry.example.com:5000/team/image:latest", true},
+		{"alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", true},
+		{"", false},
+		{"Krokhook/Slack", false},
+		{"alpine:", false},
+		{"alpine@sha256:abc", false},
+		{"with space", false},
+	} {
+		_, errs := validateImage(tc.image, "image")
+		if valid := len(errs) == 0; valid != tc.valid {
+			t.Errorf("image %q: expected valid %t, got errors %v", tc.image, tc.valid, errs)
+		}
+	}
+}
+
+func TestNextRunTime(t *testing.T) {
+	now := time.Date(2024, 5, 17, 10, 42, 13, 0, time.FixedZone("CEST", 2*60*60))
+	for _, tc := range []struct {
+		schedule string
+		want     string
+	}{
+		{"", ""},
+		{"not a schedule", ""},
+		{"@hourly", "2024-05-17T09:00:00Z"},
+		{"*/15 * * * *", "2024-05-17T08:45:00Z"},
+		{"0 3 * * *", "2024-05-18T01:00:00Z"},
+		{"@every 1h", "2024-05-17T09:42:13Z"},
+	} {
+		if got := nextRunTime(tc.schedule, now); got != tc.want {
+			t.Errorf("schedule %q: expected %q, got %q", tc.schedule, tc.want, got)
+		}
+	}
+}
@@ -1,20 +1,51 @@
 package main
 
 import (
-	"github.com/hashicorp/terraform-plugin-sdk/plugin"
-	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"context"
+	"flag"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6/tf6server"
+	"go.opentelemetry.io/otel"
 
 	"github.com/krok-o/terraform-provider-krok/krok"
+	"github.com/krok-o/terraform-provider-krok/pkg"
 )
 
+// providerAddress is the registry address of the provider.
+const providerAddress = "registry.terraform.io/krok-o/krok"
+
 var (
 	version = "v0.0.0-dev"
 )
 
 func main() {
-	plugin.Serve(&plugin.ServeOpts{
-		ProviderFunc: func() terraform.ResourceProvider {
-			return krok.Provider()
-		},
-	})
+	var debug bool
+	flag.BoolVar(&debug, "debug", false, "Start the provider in debug mode so a debugger like delve can be attached.")
+	flag.Parse()
+
+	ctx := context.Background()
+	tp, shutdown, err := pkg.NewTracerProvider(ctx, version)
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+	otel.SetTracerProvider(tp)
+	defer func() {
+		if err := shutdown(ctx); err != nil {
+			log.Printf("failed to flush traces: %v", err)
+		}
+	}()
+
+	server, err := krok.ProviderServer(ctx, version)
+	if err != nil {
+		log.Fatalf("failed to create the provider server: %v", err)
+	}
+
+	var serveOpts []tf6server.ServeOpt
+	if debug {
+		serveOpts = append(serveOpts, tf6server.WithManagedDebug())
+	}
+	if err := tf6server.Serve(providerAddress, server, serveOpts...); err != nil {
+		log.Printf("failed to serve the provider: %v", err)
+	}
 }
@@ -0,0 +1,118 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Write packages source, a single file or a directory, into the gzipped tar archive Krok expects for
+// commands and writes it to w. The contents of a directory are stored at the root of the archive.
+// Files are added in lexical order without timestamps or owners, so the same source always results
+// in the same archive.
+func Write(w io.Writer, source string) error {
+	gw := gzip.NewWriter(w)
+	if err := writeTar(gw, source); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// Hash returns the hex encoded sha256 sum of the uncompressed archive of source. The output of gzip
+// may change between Go versions, the tar stream only changes with the source.
+func Hash(source string) (string, error) {
+	h := sha256.New()
+	if err := writeTar(h, source); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeTar writes the uncompressed tar archive of source to w.
+func writeTar(w io.Writer, source string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(w)
+	if info.IsDir() {
+		err = writeDir(tw, source)
+	} else {
+		err = writeFile(tw, source, info.Name(), info)
+	}
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// writeDir adds all files below dir to tw.
+func writeDir(tw *tar.Writer, dir string) error {
+	var files []string
+	if err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir {
+			files = append(files, p)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		info, err := os.Lstat(f)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, f)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(tw, f, filepath.ToSlash(name), info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile adds a single file or directory entry called name to tw.
+func writeFile(tw *tar.Writer, file, name string, info fs.FileInfo) error {
+	switch {
+	case info.IsDir():
+		return tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     name + "/",
+			Mode:     int64(info.Mode().Perm()),
+			ModTime:  time.Unix(0, 0),
+		})
+	case info.Mode().IsRegular():
+	default:
+		return fmt.Errorf("%s is not a regular file or directory", file)
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(info.Mode().Perm()),
+		Size:     info.Size(),
+		ModTime:  time.Unix(0, 0),
+	}); err != nil {
+		return err
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	_, err = io.Copy(tw, fh)
+	return err
+}
@@ -0,0 +1,164 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// time0 is an arbitrary modification time.
+var time0 = time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+// entries returns the names and contents of the entries of a gzipped tar archive.
+func entries(t *testing.T, b []byte) map[string]string {
+	t.Helper()
+	gr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	result := make(map[string]string)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return result
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result[h.Name] = string(content)
+	}
+}
+
+// writeFiles creates files below dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWriteDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.sh": "echo hi", "lib/util.sh": "true"})
+
+	var buf bytes.Buffer
+	if err := Write(&buf, dir); err != nil {
+		t.Fatal(err)
+	}
+	got := entries(t, buf.Bytes())
+	want := map[string]string{"lib/": "", "lib/util.sh": "true", "main.sh": "echo hi"}
+	if len(got) != len(want) {
+		t.Fatalf("expected entries %v, got %v", want, got)
+	}
+	for name, content := range want {
+		if c, ok := got[name]; !ok || c != content {
+			t.Fatalf("expected %s with %q at the root of the archive, got %v", name, content, got)
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"command": "binary"})
+
+	var buf bytes.Buffer
+	if err := Write(&buf, filepath.Join(dir, "command")); err != nil {
+		t.Fatal(err)
+	}
+	got := entries(t, buf.Bytes())
+	if len(got) != 1 || got["command"] != "binary" {
+		t.Fatalf("expected only the file, got %v", got)
+	}
+}
+
+func TestHashIsReproducible(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a": "1", "b/c": "2"})
+	first, err := Hash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a changed modification time doesn't change the archive.
+	if err := os.Chtimes(filepath.Join(dir, "a"), time0, time0); err != nil {
+		t.Fatal(err)
+	}
+	second, err := Hash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatalf("expected the same hash, got %s and %s", first, second)
+	}
+
+	writeFiles(t, dir, map[string]string{"a": "changed"})
+	changed, err := Hash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first {
+		t.Fatal("expected changed contents to change the hash")
+	}
+}
+
+func TestHashIgnoresCompression(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.sh": "echo hi"})
+	source := filepath.Join(dir, "main.sh")
+	if err := os.Chmod(source, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := Hash(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the sum of the tar stream, which doesn't depend on the gzip implementation.
+	if want := "0b6e65bace6b4e9a70f413cce8e8784571f38ef7b6eb1e7132182cceee8422ad"; hash != want {
+		t.Fatalf("expected %s, got %s", want, hash)
+	}
+	var compressed bytes.Buffer
+	if err := Write(&compressed, source); err != nil {
+		t.Fatal(err)
+	}
+	gr, err := gzip.NewReader(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, gr); err != nil {
+		t.Fatal(err)
+	}
+	if uploaded := hex.EncodeToString(h.Sum(nil)); uploaded != hash {
+		t.Fatalf("expected the hash of the uploaded tar stream %s, got %s", uploaded, hash)
+	}
+}
+
+func TestWriteRejectsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"target": "secret"})
+	if err := os.Symlink(filepath.Join(dir, "target"), filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks aren't supported: %s", err)
+	}
+	err := Write(io.Discard, dir)
+	if err == nil || !strings.Contains(err.Error(), "is not a regular file or directory") {
+		t.Fatalf("expected the symlink to be rejected, got %v", err)
+	}
+}
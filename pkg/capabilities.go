@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"github.com/hashicorp/go-version"
+)
+
+// Feature is an optional server capability which resources can depend on.
+type Feature string
+
+// FeatureCommandUpload is the upload of command archives with PUT /rest/api/1/krok/command.
+const FeatureCommandUpload Feature = "command-upload"
+
+// Unreleased marks a feature of the matrix which no Krok release supports yet.
+const Unreleased = ""
+
+// CompatibilityMatrix lists the server version which introduced each feature.
+// Everything not listed here is available since MinimumServerVersion.
+var CompatibilityMatrix = map[Feature]string{
+	FeatureCommandUpload: Unreleased,
+}
+
+// Capabilities describes what the connected server supports.
+type Capabilities struct {
+	// Version is the version the capabilities are based on. Empty if it isn't known, in which case
+	// it is treated as MinimumServerVersion.
+	Version  string
+	features map[Feature]bool
+}
+
+// NewCapabilities returns the capabilities of a server running the given version.
+func NewCapabilities(serverVersion string) *Capabilities {
+	current, err := version.NewVersion(serverVersion)
+	if err != nil {
+		current = version.Must(version.NewVersion(MinimumServerVersion))
+	}
+	features := make(map[Feature]bool, len(CompatibilityMatrix))
+	for f, since := range CompatibilityMatrix {
+		features[f] = since != Unreleased && current.GreaterThanOrEqual(version.Must(version.NewVersion(since)))
+	}
+	return &Capabilities{
+		Version:  serverVersion,
+		features: features,
+	}
+}
+
+// Supports returns whether the server supports the given feature. Without detected capabilities,
+// for example with skip_preflight, only the features of MinimumServerVersion are supported.
+func (c *Capabilities) Supports(f Feature) bool {
+	if c == nil {
+		c = NewCapabilities(MinimumServerVersion)
+	}
+	supported, ok := c.features[f]
+	if !ok {
+		// not in the matrix, so it's part of the baseline.
+		return true
+	}
+	return supported
+}
@@ -0,0 +1,274 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/krok-o/krok/pkg/models"
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/terraform-provider-krok/pkg/clients/fake"
+)
+
+// serverRoutes are the routes registered by pkg/server/server.go of github.com/krok-o/krok v0.0.10.
+var serverRoutes = []string{
+	"GET /healthz",
+	"GET /readyz",
+	"POST /auth/refresh",
+	"GET /auth/login",
+	"GET /auth/callback",
+	"GET /supported-platforms",
+	"POST /rest/api/1/hooks/:rid/:vid/callback",
+	"POST /rest/api/1/get-token",
+	"POST /rest/api/1/krok/repository",
+	"GET /rest/api/1/krok/repository/:id",
+	"DELETE /rest/api/1/krok/repository/:id",
+	"POST /rest/api/1/krok/repositories",
+	"POST /rest/api/1/krok/repository/update",
+	"POST /rest/api/1/krok/command",
+	"GET /rest/api/1/krok/command/:id",
+	"DELETE /rest/api/1/krok/command/:id",
+	"POST /rest/api/1/krok/commands",
+	"POST /rest/api/1/krok/command/update",
+	"POST /rest/api/1/krok/command/add-command-rel-for-repository/:cmdid/:repoid",
+	"POST /rest/api/1/krok/command/remove-command-rel-for-repository/:cmdid/:repoid",
+	"POST /rest/api/1/krok/command/add-command-rel-for-platform/:cmdid/:pid",
+	"POST /rest/api/1/krok/command/remove-command-rel-for-platform/:cmdid/:pid",
+	"GET /rest/api/1/krok/command/settings/:id",
+	"DELETE /rest/api/1/krok/command/settings/:id",
+	"POST /rest/api/1/krok/command/:id/settings",
+	"POST /rest/api/1/krok/command/settings/update",
+	"POST /rest/api/1/krok/command/setting",
+	"GET /rest/api/1/krok/command/run/:id",
+	"POST /rest/api/1/krok/user/apikey/generate/:name",
+	"DELETE /rest/api/1/krok/user/apikey/delete/:keyid",
+	"GET /rest/api/1/krok/user/apikeys",
+	"GET /rest/api/1/krok/user/apikey/:keyid",
+	"POST /rest/api/1/krok/vcs-token",
+	"POST /rest/api/1/krok/events/:repoid",
+	"GET /rest/api/1/krok/event/:id",
+	"POST /rest/api/1/krok/vault/secret",
+	"POST /rest/api/1/krok/vault/secrets",
+	"GET /rest/api/1/krok/vault/secret/:name",
+	"POST /rest/api/1/krok/vault/secret/update",
+	"DELETE /rest/api/1/krok/vault/secret/:name",
+	"POST /rest/api/1/krok/user",
+	"POST /rest/api/1/krok/users",
+	"GET /rest/api/1/krok/user/:id",
+	"POST /rest/api/1/krok/user/update",
+	"DELETE /rest/api/1/krok/user/:id",
+}
+
+// isServerRoute returns whether a request matches one of serverRoutes, with :param segments matching
+// any segment.
+func isServerRoute(method, p string) bool {
+	segments := strings.Split(p, "/")
+	for _, r := range serverRoutes {
+		m, pattern, _ := strings.Cut(r, " ")
+		want := strings.Split(pattern, "/")
+		if m != method || len(want) != len(segments) {
+			continue
+		}
+		match := true
+		for i, w := range want {
+			if w != segments[i] && (!strings.HasPrefix(w, ":") || segments[i] == "") {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// clientCalls are the calls the provider makes. Each must only use routes Krok serves.
+var clientCalls = map[string]func(ctx context.Context, c *KrokClient) error{
+	"ServerClient.Health": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.ServerClient.Health(ctx)
+		return err
+	},
+	"CommandClient.Create": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.CommandClient.Create(ctx, &models.Command{})
+		return err
+	},
+	"CommandClient.Get": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.CommandClient.Get(ctx, 1)
+		return err
+	},
+	"CommandClient.List": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.CommandClient.List(ctx, &models.ListOptions{})
+		return err
+	},
+	"CommandClient.Update": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.CommandClient.Update(ctx, &models.Command{ID: 1})
+		return err
+	},
+	"CommandClient.Delete": func(ctx context.Context, c *KrokClient) error {
+		return c.CommandClient.Delete(ctx, 1)
+	},
+	"CommandClient.AddRelationshipToRepository": func(ctx context.Context, c *KrokClient) error {
+		return c.CommandClient.AddRelationshipToRepository(ctx, 1, 2)
+	},
+	"CommandClient.RemoveRelationshipToRepository": func(ctx context.Context, c *KrokClient) error {
+		return c.CommandClient.RemoveRelationshipToRepository(ctx, 1, 2)
+	},
+	"CommandClient.AddRelationshipToPlatform": func(ctx context.Context, c *KrokClient) error {
+		return c.CommandClient.AddRelationshipToPlatform(ctx, 1, 2)
+	},
+	"CommandClient.RemoveRelationshipToPlatform": func(ctx context.Context, c *KrokClient) error {
+		return c.CommandClient.RemoveRelationshipToPlatform(ctx, 1, 2)
+	},
+	"CommandClient.UploadArchive": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.CommandClient.UploadArchive(ctx, "test", func(w io.Writer) error { return nil })
+		return err
+	},
+	"PlatformClient.List": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.PlatformClient.List(ctx)
+		return err
+	},
+	"PlatformClient.Get": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.PlatformClient.Get(ctx, 1)
+		return err
+	},
+	"RepositoryClient.Create": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.RepositoryClient.Create(ctx, &models.Repository{})
+		return err
+	},
+	"RepositoryClient.Get": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.RepositoryClient.Get(ctx, 1)
+		return err
+	},
+	"RepositoryClient.List": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.RepositoryClient.List(ctx, &models.ListOptions{})
+		return err
+	},
+	"RepositoryClient.Update": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.RepositoryClient.Update(ctx, &models.Repository{ID: 1})
+		return err
+	},
+	"RepositoryClient.Delete": func(ctx context.Context, c *KrokClient) error {
+		return c.RepositoryClient.Delete(ctx, 1)
+	},
+	"SettingsClient.Create": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.SettingsClient.Create(ctx, &models.CommandSetting{})
+		return err
+	},
+	"SettingsClient.Get": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.SettingsClient.Get(ctx, 1)
+		return err
+	},
+	"SettingsClient.List": func(ctx context.Context, c *KrokClient) error {
+		_, err := c.SettingsClient.List(ctx, 1)
+		return err
+	},
+	"SettingsClient.Update": func(ctx context.Context, c *KrokClient) error {
+		return c.SettingsClient.Update(ctx, &models.CommandSetting{ID: 1})
+	},
+	"SettingsClient.Delete": func(ctx context.Context, c *KrokClient) error {
+		return c.SettingsClient.Delete(ctx, 1)
+	},
+	"VcsClient.Create": func(ctx context.Context, c *KrokClient) error {
+		return c.VcsClient.Create(ctx, &models.VCSToken{VCS: models.GITHUB})
+	},
+}
+
+// missingRoutes are the calls which need a route Krok doesn't serve yet.
+var missingRoutes = map[string]bool{
+	"CommandClient.UploadArchive": true,
+}
+
+func TestClientRoutes(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		requests []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		lock.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		lock.Unlock()
+		if r.URL.Path == "/supported-platforms" {
+			_, _ = w.Write([]byte(`[{"id":1,"name":"github"}]`))
+			return
+		}
+		_, _ = w.Write([]byte("null"))
+	}))
+	defer server.Close()
+	client := NewKrokClient(Config{Address: server.URL, Token: "token"}, zerolog.Nop())
+
+	for name, call := range clientCalls {
+		t.Run(name, func(t *testing.T) {
+			lock.Lock()
+			requests = nil
+			lock.Unlock()
+			if err := call(context.Background(), client); err != nil {
+				t.Fatal(err)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if len(requests) == 0 {
+				t.Fatal("expected a request")
+			}
+			for _, r := range requests {
+				method, p, _ := strings.Cut(r, " ")
+				if supported := isServerRoute(method, p); supported == missingRoutes[name] {
+					t.Errorf("%s: route is served by Krok: %t, expected %t", r, supported, !missingRoutes[name])
+				}
+			}
+		})
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	client := NewKrokClient(Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: fake.APIKeySecret,
+	}, zerolog.Nop())
+	if err := client.Preflight(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	client = NewKrokClient(Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: "wrong",
+	}, zerolog.Nop())
+	err := client.Preflight(context.Background(), server.URL)
+	if pe, ok := err.(*PreflightError); !ok || pe.Reason != PreflightCredentials {
+		t.Fatalf("expected rejected credentials, got %v", err)
+	}
+}
+
+func TestCapabilitiesSupports(t *testing.T) {
+	const feature Feature = "test"
+	CompatibilityMatrix[feature] = "v0.1.0"
+	defer delete(CompatibilityMatrix, feature)
+
+	for v, want := range map[string]bool{"": false, "v0.0.10": false, "v0.1.0": true, "v0.2.3": true} {
+		if got := NewCapabilities(v).Supports(feature); got != want {
+			t.Errorf("version %q: expected %t, got %t", v, want, got)
+		}
+	}
+	var none *Capabilities
+	if none.Supports(feature) || !none.Supports("baseline") {
+		t.Fatal("expected missing capabilities to only support the baseline")
+	}
+
+	for _, v := range []string{"", MinimumServerVersion, "v9.9.9"} {
+		if NewCapabilities(v).Supports(FeatureCommandUpload) {
+			t.Errorf("version %q: expected unreleased command uploads to be unsupported", v)
+		}
+	}
+}
@@ -12,18 +12,47 @@ import (
 	"github.com/krok-o/terraform-provider-krok/pkg/clients/platform"
 	"github.com/krok-o/terraform-provider-krok/pkg/clients/repository"
 	"github.com/krok-o/terraform-provider-krok/pkg/clients/runs"
+	"github.com/krok-o/terraform-provider-krok/pkg/clients/server"
 	"github.com/krok-o/terraform-provider-krok/pkg/clients/setting"
 	"github.com/krok-o/terraform-provider-krok/pkg/clients/user"
 	"github.com/krok-o/terraform-provider-krok/pkg/clients/vault"
 	"github.com/krok-o/terraform-provider-krok/pkg/clients/vcs"
 )
 
+// ErrNotFound is returned by the clients when the requested object doesn't exist on the server.
+var ErrNotFound = clients.ErrNotFound
+
 // Config defines configuration for the Krok server client.
 type Config struct {
 	APIKeyID     string
 	APIKeySecret string
 	Address      string
 	Email        string
+	// Token, TokenFile and TokenCommand replace the exchange of the email and API key for a token.
+	// Only one of them is used, in this order.
+	Token        string
+	TokenFile    string
+	TokenCommand string
+	// TokenCacheDir persists tokens of the API key exchange across processes. Empty disables the cache.
+	TokenCacheDir string
+	// RequestsPerSecond and MaxConcurrentRequests limit the load on the server. Zero means no limit.
+	RequestsPerSecond     float64
+	MaxConcurrentRequests int
+	// CacheReads caches reads of commands, platforms and repositories until they are changed by the client.
+	CacheReads bool
+}
+
+// tokenSource returns the token source for the configured authentication mode, or nil for the API key exchange.
+func (c Config) tokenSource() clients.TokenSource {
+	switch {
+	case c.Token != "":
+		return clients.StaticToken(c.Token)
+	case c.TokenFile != "":
+		return clients.TokenFile(c.TokenFile)
+	case c.TokenCommand != "":
+		return clients.TokenCommand(c.TokenCommand)
+	}
+	return nil
 }
 
 // KrokClient is the main client for the Krok server.
@@ -35,21 +64,32 @@ type KrokClient struct {
 	EventClient      *event.Client
 	PlatformClient   *platform.Client
 	RepositoryClient *repository.Client
+	ServerClient     *server.Client
 	SettingsClient   *setting.Client
 	UserClient       *user.Client
 	VaultClient      *vault.Client
 	VcsClient        *vcs.Client
+	Logger           zerolog.Logger
+	// Capabilities of the server. Until they are detected, only the baseline features are assumed.
+	Capabilities *Capabilities
+	// ImagePolicy restricts the images of commands. The default policy allows every image.
+	ImagePolicy *ImagePolicy
 }
 
 // NewKrokClient creates a new Krok server client.
 func NewKrokClient(cfg Config, log zerolog.Logger) *KrokClient {
 	handler := clients.NewHandler(clients.Config{
-		APIKeyID:     cfg.APIKeyID,
-		APIKeySecret: cfg.APIKeySecret,
-		Address:      cfg.Address,
-		Email:        cfg.Email,
-		Client:       http.DefaultClient,
-		Logger:       log,
+		APIKeyID:              cfg.APIKeyID,
+		APIKeySecret:          cfg.APIKeySecret,
+		Address:               cfg.Address,
+		Email:                 cfg.Email,
+		Client:                http.DefaultClient,
+		Logger:                log,
+		TokenSource:           cfg.tokenSource(),
+		TokenCacheDir:         cfg.TokenCacheDir,
+		RequestsPerSecond:     cfg.RequestsPerSecond,
+		MaxConcurrentRequests: cfg.MaxConcurrentRequests,
+		CacheReads:            cfg.CacheReads,
 	})
 	apiKeyClient := auth.NewClient(cfg.Address, log, handler)
 	commandClient := command.NewClient(cfg.Address, log, handler)
@@ -57,6 +97,7 @@ func NewKrokClient(cfg Config, log zerolog.Logger) *KrokClient {
 	eventsClient := event.NewClient(cfg.Address, log, handler)
 	platformClient := platform.NewClient(cfg.Address, log, handler)
 	repoClient := repository.NewClient(cfg.Address, log, handler)
+	serverClient := server.NewClient(cfg.Address, log, handler)
 	settingsClient := setting.NewClient(cfg.Address, log, handler)
 	userClient := user.NewClient(cfg.Address, log, handler)
 	vaultClient := vault.NewClient(cfg.Address, log, handler)
@@ -68,9 +109,13 @@ func NewKrokClient(cfg Config, log zerolog.Logger) *KrokClient {
 		EventClient:      eventsClient,
 		PlatformClient:   platformClient,
 		RepositoryClient: repoClient,
+		ServerClient:     serverClient,
 		SettingsClient:   settingsClient,
 		UserClient:       userClient,
 		VaultClient:      vaultClient,
 		VcsClient:        vcsClient,
+		Logger:           log,
+		Capabilities:     NewCapabilities(""),
+		ImagePolicy:      &ImagePolicy{},
 	}
 }
@@ -7,7 +7,6 @@ import (
 	"net/url"
 	"path"
 	"strconv"
-	"time"
 
 	"github.com/krok-o/krok/pkg/models"
 	"github.com/rs/zerolog"
@@ -16,9 +15,8 @@ import (
 )
 
 const (
-	timeOutInSeconds = 10
-	apiKeyURI        = "/rest/api/1/krok/user/apikey"
-	apiKeysURI       = "/rest/api/1/krok/user/apikeys"
+	apiKeyURI  = "/rest/api/1/krok/user/apikey"
+	apiKeysURI = "/rest/api/1/krok/user/apikeys"
 )
 
 // NewClient creates a new api key provider.
@@ -38,8 +36,8 @@ type Client struct {
 }
 
 // Create creates a repository resource.
-func (c *Client) Create(name string) (*models.APIKey, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Create(ctx context.Context, name string) (*models.APIKey, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -63,8 +61,8 @@ func (c *Client) Create(name string) (*models.APIKey, error) {
 }
 
 // List api keys.
-func (c *Client) List() ([]*models.APIKey, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) List(ctx context.Context) ([]*models.APIKey, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -88,8 +86,8 @@ func (c *Client) List() ([]*models.APIKey, error) {
 }
 
 // Delete deletes an api key resource.
-func (c *Client) Delete(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Delete(ctx context.Context, id int) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -111,8 +109,8 @@ func (c *Client) Delete(id int) error {
 }
 
 // Get returns a api key resource.
-func (c *Client) Get(id int) (*models.APIKey, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Get(ctx context.Context, id int) (*models.APIKey, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
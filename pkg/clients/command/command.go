@@ -1,7 +1,6 @@
 package command
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -13,7 +12,7 @@ import (
 	"path"
 	"path/filepath"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/rs/zerolog"
 
@@ -23,9 +22,8 @@ import (
 )
 
 const (
-	timeOutInSeconds = 10
-	commandURI       = "/rest/api/1/krok/command"
-	commandsURI      = "/rest/api/1/krok/commands"
+	commandURI  = "/rest/api/1/krok/command"
+	commandsURI = "/rest/api/1/krok/commands"
 )
 
 // NewClient creates a new command provider.
@@ -45,8 +43,8 @@ type Client struct {
 }
 
 // Create creates a command resource.
-func (c *Client) Create(command *models.Command) (*models.Command, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Create(ctx context.Context, command *models.Command) (*models.Command, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(command)
@@ -75,52 +73,58 @@ func (c *Client) Create(command *models.Command) (*models.Command, error) {
 	return &result, nil
 }
 
-// Upload uploads a command resource.
-func (c *Client) Upload(file string) (*models.Command, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
-	defer cancel()
-
-	bodyBuf := &bytes.Buffer{}
-	bodyWriter := multipart.NewWriter(bodyBuf)
-
-	// this step is very important
-	filename := filepath.Base(file)
-	fileWriter, err := bodyWriter.CreateFormFile("file", filename)
-	if err != nil {
-		c.Logger.Debug().Err(err).Msg("Error writing to buffer.")
-		return nil, err
-	}
-
-	// open file handle
-	fh, err := os.Open(filename)
-	if err != nil {
-		c.Logger.Debug().Err(err).Msg("Failed to open file.")
-		return nil, err
-	}
-	defer func(fh *os.File) {
-		_ = fh.Close()
-	}(fh)
-
-	if _, err = io.Copy(fileWriter, fh); err != nil {
-		c.Logger.Debug().Err(err).Msg("Failed to copy to fileWriter")
-		return nil, err
-	}
+// Upload uploads the command archive at the given path. The archive is named after the file.
+func (c *Client) Upload(ctx context.Context, file string) (*models.Command, error) {
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(file), ".gz"), ".tar")
+	return c.UploadArchive(ctx, name, func(w io.Writer) error {
+		fh, err := os.Open(file)
+		if err != nil {
+			c.Logger.Debug().Err(err).Msg("Failed to open file.")
+			return err
+		}
+		defer fh.Close()
+		_, err = io.Copy(w, fh)
+		return err
+	})
+}
 
-	contentType := bodyWriter.FormDataContentType()
-	_ = bodyWriter.Close()
+// UploadArchive uploads a command archive called name. The archive is written by write while it is
+// sent, so it's never held in memory. write may be called more than once if the request is retried.
+func (c *Client) UploadArchive(ctx context.Context, name string, write func(w io.Writer) error) (*models.Command, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
+	defer cancel()
 
 	u, err := url.Parse(c.Address)
 	if err != nil {
 		c.Logger.Debug().Err(err).Msg("Failed to parse address")
 		return nil, err
 	}
+	// the boundary has to be known up front for the content type, so every attempt uses the same one.
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	body := func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			bodyWriter := multipart.NewWriter(pw)
+			_ = bodyWriter.SetBoundary(boundary)
+			pw.CloseWithError(writeArchive(bodyWriter, name, write))
+		}()
+		return pr, nil
+	}
 	result := models.Command{}
 	u.Path = path.Join(u.Path, commandURI)
-	code, err := c.Handler.MakeRequest(ctx, http.MethodPut, u.String(), clients.WithPayload(bodyBuf.Bytes()), clients.WithOutput(&result), clients.WithContentType(contentType))
+	code, err := c.Handler.MakeRequest(ctx, http.MethodPut, u.String(),
+		clients.WithBody(body),
+		clients.WithOutput(&result),
+		clients.WithContentType("multipart/form-data; boundary="+boundary),
+	)
 	if err != nil {
 		c.Logger.Debug().Err(err).Int("code", code).Msg("Failed to get result.")
 		return nil, err
 	}
+	if code == http.StatusNotFound || code == http.StatusMethodNotAllowed {
+		c.Logger.Error().Str("url", u.String()).Int("code", code).Msg("Server doesn't accept command uploads")
+		return nil, fmt.Errorf("the server doesn't accept command uploads, return code was not OK %d", code)
+	}
 	if code > 299 || code < 200 {
 		c.Logger.Error().Str("url", u.String()).Int("code", code).Msg("Return code was not OK")
 		return nil, fmt.Errorf("return code was not OK %d", code)
@@ -128,9 +132,24 @@ func (c *Client) Upload(file string) (*models.Command, error) {
 	return &result, nil
 }
 
+// writeArchive writes the multipart form of an upload.
+func writeArchive(bodyWriter *multipart.Writer, name string, write func(w io.Writer) error) error {
+	if err := bodyWriter.WriteField("name", name); err != nil {
+		return err
+	}
+	fileWriter, err := bodyWriter.CreateFormFile("file", name+".tar.gz")
+	if err != nil {
+		return err
+	}
+	if err := write(fileWriter); err != nil {
+		return err
+	}
+	return bodyWriter.Close()
+}
+
 // Update updates a command resource.
-func (c *Client) Update(repo *models.Command) (*models.Command, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Update(ctx context.Context, repo *models.Command) (*models.Command, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(repo)
@@ -160,8 +179,8 @@ func (c *Client) Update(repo *models.Command) (*models.Command, error) {
 }
 
 // Delete deletes a command resource.
-func (c *Client) Delete(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Delete(ctx context.Context, id int) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -183,8 +202,8 @@ func (c *Client) Delete(id int) error {
 }
 
 // List repositories.
-func (c *Client) List(opts *models.ListOptions) ([]*models.Command, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) List(ctx context.Context, opts *models.ListOptions) ([]*models.Command, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(opts)
@@ -214,8 +233,8 @@ func (c *Client) List(opts *models.ListOptions) ([]*models.Command, error) {
 }
 
 // Get returns a command resource.
-func (c *Client) Get(id int) (*models.Command, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Get(ctx context.Context, id int) (*models.Command, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -231,6 +250,9 @@ func (c *Client) Get(id int) (*models.Command, error) {
 		c.Logger.Debug().Err(err).Int("code", code).Msg("Failed to get result.")
 		return nil, err
 	}
+	if code == http.StatusNotFound {
+		return nil, fmt.Errorf("command %d %w, return code was not OK %d", id, clients.ErrNotFound, code)
+	}
 	if code > 299 || code < 200 {
 		c.Logger.Error().Str("url", u.String()).Int("code", code).Msg("Return code was not OK")
 		return nil, fmt.Errorf("return code was not OK %d", code)
@@ -239,8 +261,8 @@ func (c *Client) Get(id int) (*models.Command, error) {
 }
 
 // AddRelationshipToRepository adds a relationship to a repository.
-func (c *Client) AddRelationshipToRepository(commandID int, repositoryID int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) AddRelationshipToRepository(ctx context.Context, commandID int, repositoryID int) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -263,8 +285,8 @@ func (c *Client) AddRelationshipToRepository(commandID int, repositoryID int) er
 }
 
 // RemoveRelationshipToRepository adds a relationship to a repository.
-func (c *Client) RemoveRelationshipToRepository(commandID int, repositoryID int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) RemoveRelationshipToRepository(ctx context.Context, commandID int, repositoryID int) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -287,8 +309,8 @@ func (c *Client) RemoveRelationshipToRepository(commandID int, repositoryID int)
 }
 
 // RemoveRelationshipToPlatform adds a relationship to a platform.
-func (c *Client) RemoveRelationshipToPlatform(commandID int, platformID int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) RemoveRelationshipToPlatform(ctx context.Context, commandID int, platformID int) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -311,8 +333,8 @@ func (c *Client) RemoveRelationshipToPlatform(commandID int, platformID int) err
 }
 
 // AddRelationshipToPlatform adds a relationship to a platform.
-func (c *Client) AddRelationshipToPlatform(commandID int, platformID int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) AddRelationshipToPlatform(ctx context.Context, commandID int, platformID int) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -8,7 +8,6 @@ import (
 	"net/url"
 	"path"
 	"strconv"
-	"time"
 
 	"github.com/rs/zerolog"
 
@@ -18,9 +17,8 @@ import (
 )
 
 const (
-	timeOutInSeconds = 10
-	eventURI         = "/rest/api/1/krok/event"
-	eventsURI        = "/rest/api/1/krok/events"
+	eventURI  = "/rest/api/1/krok/event"
+	eventsURI = "/rest/api/1/krok/events"
 )
 
 // NewClient creates a new event provider.
@@ -40,8 +38,8 @@ type Client struct {
 }
 
 // List events.
-func (c *Client) List(repoID int, opts *models.ListOptions) ([]*models.Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) List(ctx context.Context, repoID int, opts *models.ListOptions) ([]*models.Event, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(opts)
@@ -71,8 +69,8 @@ func (c *Client) List(repoID int, opts *models.ListOptions) ([]*models.Event, er
 }
 
 // Get returns a event resource.
-func (c *Client) Get(id int) (*models.Event, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Get(ctx context.Context, id int) (*models.Event, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
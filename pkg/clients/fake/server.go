@@ -0,0 +1,293 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/krok-o/krok/pkg/models"
+)
+
+const (
+	// Email is the email the fake server accepts.
+	Email = "admin@krok.app"
+	// APIKeyID is the api key id the fake server accepts.
+	APIKeyID = "api-key-id"
+	// APIKeySecret is the api key secret the fake server accepts.
+	APIKeySecret = "api-key-secret"
+)
+
+// route is an endpoint of the fake server.
+type route struct {
+	method string
+	prefix string
+	public bool
+	handle func(s *Server, w http.ResponseWriter, r *http.Request, param string)
+}
+
+// routes are the endpoints served by the fake server, a subset of the routes of Krok v0.0.10. A route
+// matches if the path starts with prefix, the rest of the path is passed to the handler.
+var routes = []route{
+	{method: http.MethodGet, prefix: "/healthz", public: true, handle: handleHealth},
+	{method: http.MethodGet, prefix: "/supported-platforms", public: true, handle: handleListPlatforms},
+	{method: http.MethodPost, prefix: "/rest/api/1/get-token", public: true, handle: handleToken},
+	{method: http.MethodPost, prefix: "/rest/api/1/krok/vcs-token", handle: handleCreateVCSToken},
+	{method: http.MethodGet, prefix: "/rest/api/1/krok/repository/", handle: handleGetRepository},
+	{method: http.MethodGet, prefix: "/rest/api/1/krok/command/", handle: handleGetCommand},
+	{method: http.MethodPost, prefix: "/rest/api/1/krok/command/add-command-rel-for-repository/", handle: handleRepositoryRelationship(true)},
+	{method: http.MethodPost, prefix: "/rest/api/1/krok/command/remove-command-rel-for-repository/", handle: handleRepositoryRelationship(false)},
+	{method: http.MethodPost, prefix: "/rest/api/1/krok/command/add-command-rel-for-platform/", handle: handlePlatformRelationship(true)},
+	{method: http.MethodPost, prefix: "/rest/api/1/krok/command/remove-command-rel-for-platform/", handle: handlePlatformRelationship(false)},
+	{method: http.MethodPost, prefix: "/rest/api/1/krok/command/", handle: handleListSettings},
+	{method: http.MethodPost, prefix: "/rest/api/1/krok/commands", handle: handleListCommands},
+	{method: http.MethodPost, prefix: "/rest/api/1/krok/repositories", handle: handleListRepositories},
+}
+
+// Server is an in-memory Krok server for tests.
+type Server struct {
+	*httptest.Server
+
+	lock         sync.Mutex
+	token        string
+	vcsTokens    map[int]string
+	repositories map[int]*models.Repository
+	commands     map[int]*models.Command
+	settings     map[int][]*models.CommandSetting
+}
+
+// NewServer starts a new fake server. The server has to be closed by the caller.
+func NewServer() *Server {
+	s := &Server{
+		token:        "fake-token",
+		vcsTokens:    make(map[int]string),
+		repositories: make(map[int]*models.Repository),
+		commands:     make(map[int]*models.Command),
+		settings:     make(map[int][]*models.CommandSetting),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// AddRepository stores repo on the server.
+func (s *Server) AddRepository(repo *models.Repository) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.repositories[repo.ID] = repo
+}
+
+// AddCommand stores command on the server.
+func (s *Server) AddCommand(command *models.Command) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.commands[command.ID] = command
+}
+
+// AddCommandSetting stores setting on the server.
+func (s *Server) AddCommandSetting(setting *models.CommandSetting) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.settings[setting.CommandID] = append(s.settings[setting.CommandID], setting)
+}
+
+// serve dispatches a request to the first matching route.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	for _, rt := range routes {
+		if r.Method != rt.method || !strings.HasPrefix(r.URL.Path, rt.prefix) {
+			continue
+		}
+		param := strings.TrimPrefix(r.URL.Path, rt.prefix)
+		if strings.HasSuffix(rt.prefix, "/") == (param == "") {
+			continue
+		}
+		if !rt.public && r.Header.Get("Authorization") != "Bearer "+s.token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.lock.Lock()
+		rt.handle(s, w, r, param)
+		s.lock.Unlock()
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func handleHealth(_ *Server, w http.ResponseWriter, _ *http.Request, _ string) {
+	_, _ = w.Write([]byte("alive"))
+}
+
+func handleListPlatforms(_ *Server, w http.ResponseWriter, _ *http.Request, _ string) {
+	platforms := make([]models.Platform, 0, len(models.SupportedPlatforms))
+	for _, p := range models.SupportedPlatforms {
+		platforms = append(platforms, p)
+	}
+	sort.Slice(platforms, func(i, j int) bool {
+		return platforms[i].ID < platforms[j].ID
+	})
+	writeJSON(w, platforms)
+}
+
+func handleToken(s *Server, w http.ResponseWriter, r *http.Request, _ string) {
+	var req models.APIKeyAuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Email != Email || req.APIKeyID != APIKeyID || req.APIKeySecret != APIKeySecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, models.TokenResponse{Token: s.token})
+}
+
+func handleCreateVCSToken(s *Server, w http.ResponseWriter, r *http.Request, _ string) {
+	var req models.VCSToken
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.vcsTokens[req.VCS] = req.Token
+	w.WriteHeader(http.StatusCreated)
+}
+
+func handleGetRepository(s *Server, w http.ResponseWriter, _ *http.Request, param string) {
+	id, err := strconv.Atoi(param)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	repo, ok := s.repositories[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, repo)
+}
+
+func handleGetCommand(s *Server, w http.ResponseWriter, _ *http.Request, param string) {
+	id, err := strconv.Atoi(param)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	command, ok := s.commands[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, command)
+}
+
+// handleListCommands returns every command, ignoring the list options.
+func handleListCommands(s *Server, w http.ResponseWriter, _ *http.Request, _ string) {
+	commands := make([]*models.Command, 0, len(s.commands))
+	for _, c := range s.commands {
+		commands = append(commands, c)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].ID < commands[j].ID
+	})
+	writeJSON(w, commands)
+}
+
+// handleListRepositories returns every repository. Like Krok, the list doesn't contain the secret and
+// the commands of the repositories.
+func handleListRepositories(s *Server, w http.ResponseWriter, _ *http.Request, _ string) {
+	repositories := make([]*models.Repository, 0, len(s.repositories))
+	for _, r := range s.repositories {
+		listed := *r
+		listed.Auth, listed.Commands = nil, nil
+		repositories = append(repositories, &listed)
+	}
+	sort.Slice(repositories, func(i, j int) bool {
+		return repositories[i].ID < repositories[j].ID
+	})
+	writeJSON(w, repositories)
+}
+
+// handleListSettings returns the settings of the command in a path like {id}/settings.
+func handleListSettings(s *Server, w http.ResponseWriter, _ *http.Request, param string) {
+	c, ok := strings.CutSuffix(param, "/settings")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	id, err := strconv.Atoi(c)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	settings := s.settings[id]
+	if settings == nil {
+		settings = []*models.CommandSetting{}
+	}
+	writeJSON(w, settings)
+}
+
+// relationshipIDs parses a parameter like command_id/target_id. Like Krok, changing the relationships of a
+// missing command fails with an internal server error.
+func relationshipIDs(s *Server, w http.ResponseWriter, param string) (*models.Command, int, bool) {
+	c, t, _ := strings.Cut(param, "/")
+	commandID, err := strconv.Atoi(c)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, 0, false
+	}
+	targetID, err := strconv.Atoi(t)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, 0, false
+	}
+	command, ok := s.commands[commandID]
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, 0, false
+	}
+	return command, targetID, true
+}
+
+func handleRepositoryRelationship(attach bool) func(s *Server, w http.ResponseWriter, r *http.Request, param string) {
+	return func(s *Server, w http.ResponseWriter, _ *http.Request, param string) {
+		command, id, ok := relationshipIDs(s, w, param)
+		if !ok {
+			return
+		}
+		i := slices.IndexFunc(command.Repositories, func(r *models.Repository) bool { return r.ID == id })
+		switch {
+		case attach && i < 0:
+			command.Repositories = append(command.Repositories, &models.Repository{ID: id})
+		case !attach && i >= 0:
+			command.Repositories = slices.Delete(command.Repositories, i, i+1)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+func handlePlatformRelationship(attach bool) func(s *Server, w http.ResponseWriter, r *http.Request, param string) {
+	return func(s *Server, w http.ResponseWriter, _ *http.Request, param string) {
+		command, id, ok := relationshipIDs(s, w, param)
+		if !ok {
+			return
+		}
+		i := slices.IndexFunc(command.Platforms, func(p models.Platform) bool { return p.ID == id })
+		switch {
+		case attach && i < 0:
+			command.Platforms = append(command.Platforms, models.Platform{ID: id})
+		case !attach && i >= 0:
+			command.Platforms = slices.Delete(command.Platforms, i, i+1)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+// writeJSON writes v as the response body.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -3,22 +3,59 @@ package clients
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/krok-o/krok/pkg/models"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 )
 
-const getTokenURI = "/rest/api/1/get-token"
+const (
+	getTokenURI     = "/rest/api/1/get-token"
+	requestIDHeader = "X-Request-ID"
+	tracerName      = "github.com/krok-o/terraform-provider-krok/pkg/clients"
+)
+
+// DefaultTimeout is the timeout of a call to the Krok API if its context has no deadline yet.
+const DefaultTimeout = 10 * time.Second
+
+// WithTimeout returns ctx with DefaultTimeout, unless ctx already has a deadline, like the one of a
+// Terraform operation.
+func WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, DefaultTimeout)
+}
+
+// ErrAuthentication is returned when the server rejects the configured credentials.
+var ErrAuthentication = errors.New("failed to authenticate")
+
+// ErrNotFound is returned when the requested object doesn't exist on the server.
+var ErrNotFound = errors.New("not found")
 
 // Handler makes requests to krok server.
 type Handler interface {
 	MakeRequest(ctx context.Context, method string, url string, opts ...MakeRequestOptions) (int, error)
+	Authenticate(ctx context.Context) error
 }
 
 // Config contains configuration entries for the handler.
@@ -29,27 +66,78 @@ type Config struct {
 	APIKeySecret string
 	Email        string
 	Logger       zerolog.Logger
+	// TracerProvider is used to create spans for every request. Defaults to the global provider.
+	TracerProvider trace.TracerProvider
+	// TokenSource provides the tokens requests are authenticated with. Defaults to exchanging
+	// the email and API key for a token.
+	TokenSource TokenSource
+	// TokenCacheDir is where tokens from the API key exchange are cached across processes. Empty disables the cache.
+	TokenCacheDir string
+	// RequestsPerSecond limits the rate of requests, which are spread out evenly. Zero means no limit.
+	RequestsPerSecond float64
+	// MaxConcurrentRequests limits the number of requests in flight at the same time. Zero means no limit.
+	MaxConcurrentRequests int
+	// CacheReads caches the responses of reads of commands, platforms and repositories until they are
+	// changed through the handler.
+	CacheReads bool
 }
 
 // NewHandler creates a new handler with a given client.
 func NewHandler(cfg Config) *KrokHandler {
-	return &KrokHandler{
-		Config: cfg,
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	handler := &KrokHandler{
+		Config:     cfg,
+		tracer:     cfg.TracerProvider.Tracer(tracerName),
+		propagator: propagation.TraceContext{},
+	}
+	if cfg.RequestsPerSecond > 0 {
+		handler.limiter = rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), 1)
+	}
+	if cfg.MaxConcurrentRequests > 0 {
+		handler.inFlight = make(chan struct{}, cfg.MaxConcurrentRequests)
+	}
+	if cfg.CacheReads {
+		handler.cache = newReadCache()
 	}
+	if handler.TokenSource == nil {
+		handler.TokenSource = &apiKeyTokenSource{handler: handler}
+		if cfg.TokenCacheDir != "" {
+			handler.TokenSource = &FileTokenCache{
+				Source: handler.TokenSource,
+				Dir:    cfg.TokenCacheDir,
+				Key:    TokenCacheKey(cfg.Address, cfg.Email, cfg.APIKeyID),
+				Logger: cfg.Logger,
+			}
+		}
+	}
+	return handler
 }
 
 // KrokHandler has methods which can deal with talking to REST endpoints.
 type KrokHandler struct {
 	Config
 
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	tokenLock  sync.Mutex
 	tokenCache string
+	// limiter and inFlight are nil if requests aren't limited.
+	limiter  *rate.Limiter
+	inFlight chan struct{}
+	// cache is nil if reads aren't cached.
+	cache *readCache
 }
 
 // MakeRequestOption defines options for MakeRequest call.
 type MakeRequestOption struct {
 	data        []byte
+	body        func() (io.ReadCloser, error)
 	output      interface{}
 	contentType string
+	anonymous   bool
+	uncached    bool
 }
 
 // MakeRequestOptions defines functional options for optional parameters.
@@ -62,6 +150,14 @@ func WithPayload(payload []byte) MakeRequestOptions {
 	}
 }
 
+// WithBody streams the payload from the reader returned by body instead of holding it in memory.
+// body is called for every attempt, so the payload can be sent again if the request is retried.
+func WithBody(body func() (io.ReadCloser, error)) MakeRequestOptions {
+	return func(option *MakeRequestOption) {
+		option.body = body
+	}
+}
+
 // WithOutput adds an output if there is something to get back from MakeRequest like a Get call.
 func WithOutput(out interface{}) MakeRequestOptions {
 	return func(option *MakeRequestOption) {
@@ -76,6 +172,20 @@ func WithContentType(ct string) MakeRequestOptions {
 	}
 }
 
+// WithoutAuthentication sends the request without a token, for endpoints which are public.
+func WithoutAuthentication() MakeRequestOptions {
+	return func(option *MakeRequestOption) {
+		option.anonymous = true
+	}
+}
+
+// withoutCache bypasses the read cache, for filling it.
+func withoutCache() MakeRequestOptions {
+	return func(option *MakeRequestOption) {
+		option.uncached = true
+	}
+}
+
 // MakeRequest sends a request to the designated URL.
 // @data - optional data to send along if it is a POST request.
 // @url - defines the destination.
@@ -90,37 +200,156 @@ func (p *KrokHandler) MakeRequest(ctx context.Context, method string, url string
 	for _, o := range opts {
 		o(mos)
 	}
-	payload := bytes.NewReader(mos.data)
-	return p.prepare(ctx, method, url, payload, mos.output, mos.contentType)
+	if p.cache != nil && !mos.uncached {
+		if method == http.MethodGet {
+			if kind := cacheKind(url); kind != "" {
+				return p.cachedRequest(ctx, url, kind, mos)
+			}
+		} else if kinds := invalidatedKinds(url); kinds != nil {
+			// the write might have been applied even if it failed.
+			defer p.cache.invalidate(kinds)
+		}
+	}
+
+	route := routeTemplate(url)
+	ctx, span := p.tracer.Start(ctx, method+" "+route,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.HTTPRoute(route),
+		),
+	)
+	defer span.End()
+
+	code, retries, err := p.send(ctx, method, url, mos)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(code), attribute.Int("krok.retries", retries))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if code >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(code))
+	}
+	return code, err
+}
+
+// send sends the request. If Krok rejects a token which has been used before, the token has most likely
+// expired: it's dropped, a new one is fetched from the token source, and the request is sent once more.
+// This is how tokens of token_file, token_command and the token cache are replaced. The number of retries
+// is returned.
+func (p *KrokHandler) send(ctx context.Context, method, url string, mos *MakeRequestOption) (code int, retries int, err error) {
+	for {
+		var (
+			reauthenticate bool
+			payload        io.Reader = bytes.NewReader(mos.data)
+		)
+		if mos.body != nil {
+			body, bodyErr := mos.body()
+			if bodyErr != nil {
+				return http.StatusInternalServerError, retries, bodyErr
+			}
+			payload = body
+		}
+		code, reauthenticate, err = p.prepare(ctx, method, url, payload, mos.output, mos.contentType, mos.anonymous)
+		// the transport closes a streamed body once it's sent, but not if the request failed before that.
+		if c, ok := payload.(io.Closer); ok {
+			_ = c.Close()
+		}
+		if !reauthenticate || retries > 0 {
+			return code, retries, err
+		}
+		retries++
+	}
+}
+
+// cachedRequest returns the cached response of a read, or makes the request and caches its response.
+func (p *KrokHandler) cachedRequest(ctx context.Context, url, kind string, mos *MakeRequestOption) (int, error) {
+	body, ok, generation, hits, misses := p.cache.get(url, kind)
+	p.Logger.Debug().Str("path", routeTemplate(url)).Bool("hit", ok).Int("hits", hits).Int("misses", misses).Msg("Read cache lookup.")
+	if !ok {
+		var raw json.RawMessage
+		code, err := p.MakeRequest(ctx, http.MethodGet, url, WithOutput(&raw), WithContentType(mos.contentType), withoutCache())
+		if err != nil || code < 200 || code > 299 {
+			return code, err
+		}
+		body = raw
+		if !p.cache.put(url, kind, generation, body) {
+			p.Logger.Debug().Str("path", routeTemplate(url)).Msg("Not caching a read which overlapped a write.")
+		}
+	}
+	if mos.output != nil {
+		if err := p.parseBody(bytes.NewReader(body), mos.output); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	return http.StatusOK, nil
 }
 
 // prepare the request. Any possible result will be put into the parseTo variable.
-func (p *KrokHandler) prepare(ctx context.Context, method, url string, payload io.Reader, parseTo interface{}, contentType string) (int, error) {
+// If a cached token was rejected, the cache is cleared and true is returned so the request can be retried.
+func (p *KrokHandler) prepare(ctx context.Context, method, url string, payload io.Reader, parseTo interface{}, contentType string, anonymous bool) (int, bool, error) {
 	req, err := http.NewRequestWithContext(ctx, method, url, payload)
 	if err != nil {
 		p.Logger.Error().Err(err).Msg("Failed to create HTTP request.")
-		return http.StatusInternalServerError, err
+		return http.StatusInternalServerError, false, err
 	}
 	req.Header.Add("Content-Type", contentType)
+	if anonymous {
+		response, err := p.Send(req, parseTo)
+		if err != nil {
+			return http.StatusInternalServerError, false, err
+		}
+		return response.StatusCode, false, nil
+	}
+	p.tokenLock.Lock()
 	token := p.tokenCache
-	if token == "" {
-		if token, err = p.authenticate(); err != nil {
-			return http.StatusInternalServerError, err
+	cached := token != ""
+	if !cached {
+		if token, err = p.TokenSource.Token(ctx); err != nil {
+			p.tokenLock.Unlock()
+			return http.StatusInternalServerError, false, err
 		}
 		// any subsequent calls to the api during this run instance should come from a cached
 		// record instead of constantly calling out to authenticate.
 		p.tokenCache = token
 	}
+	p.tokenLock.Unlock()
 	req.Header.Add("Authorization", "Bearer "+token)
 	response, err := p.Send(req, parseTo)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return http.StatusInternalServerError, false, err
 	}
-	return response.StatusCode, nil
+	if response.StatusCode == http.StatusUnauthorized && cached {
+		p.tokenLock.Lock()
+		if p.tokenCache == token {
+			p.tokenCache = ""
+		}
+		p.tokenLock.Unlock()
+		if i, ok := p.TokenSource.(TokenInvalidator); ok {
+			i.Invalidate(token)
+		}
+		return response.StatusCode, true, nil
+	}
+	return response.StatusCode, false, nil
+}
+
+// Authenticate fetches a new token from the token source and caches it. This can be used to verify the
+// configured credentials before any other request is made.
+func (p *KrokHandler) Authenticate(ctx context.Context) error {
+	p.tokenLock.Lock()
+	defer p.tokenLock.Unlock()
+	token, err := p.TokenSource.Token(ctx)
+	if err != nil {
+		return err
+	}
+	p.tokenCache = token
+	return nil
 }
 
 // authenticate call the API to get token.
-func (p *KrokHandler) authenticate() (string, error) {
+func (p *KrokHandler) authenticate(ctx context.Context) (string, error) {
+	ctx, span := p.tracer.Start(ctx, "krok.authenticate", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
 	u, err := url.Parse(p.Address)
 	if err != nil {
 		return "", err
@@ -136,7 +365,7 @@ func (p *KrokHandler) authenticate() (string, error) {
 		p.Logger.Debug().Err(err).Msg("Failed to parse repository")
 		return "", err
 	}
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, u.String(), bytes.NewReader(b))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(b))
 	if err != nil {
 		p.Logger.Error().Err(err).Msg("Failed to create HTTP request.")
 		return "", err
@@ -150,21 +379,45 @@ func (p *KrokHandler) authenticate() (string, error) {
 			code = resp.StatusCode
 		}
 		p.Logger.Debug().Err(err).Int("code", code).Msg("failed to authenticate")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return "", err
 	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		p.Logger.Debug().Err(err).Int("code", resp.StatusCode).Msg("failed to authenticate")
-		return "", errors.New("failed to authenticate")
+		span.SetStatus(codes.Error, ErrAuthentication.Error())
+		return "", fmt.Errorf("%w: return code was not OK %d", ErrAuthentication, resp.StatusCode)
 	}
 	return result.Token, nil
 }
 
 // Send extracts the common operation to send over the wire.
+// Every request is logged at debug level. Request and response payloads are logged at trace
+// level with all secrets redacted.
 func (p *KrokHandler) Send(req *http.Request, parseTo interface{}) (*http.Response, error) {
+	requestID := newRequestID()
+	req.Header.Set(requestIDHeader, requestID)
+	p.propagator.Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	if e := p.Logger.Trace(); e.Enabled() && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(body)
+			e.Str("request_id", requestID).Str("payload", redact(b)).Msg("Request payload.")
+		}
+	}
+
+	queued := time.Now()
+	if err := p.wait(req.Context()); err != nil {
+		p.Logger.Debug().Err(err).Str("method", req.Method).Str("path", req.URL.Path).Str("request_id", requestID).Msg("Request wasn't sent.")
+		return nil, err
+	}
+	defer p.done()
+
 	// Send the request
+	start := time.Now()
 	resp, err := p.Client.Do(req)
 	if err != nil {
-		p.Logger.Error().Err(err).Msg("Failed to call endpoint.")
+		p.Logger.Error().Err(err).Str("method", req.Method).Str("path", req.URL.Path).Str("request_id", requestID).Msg("Failed to call endpoint.")
 		return nil, err
 	}
 	defer func() {
@@ -172,14 +425,68 @@ func (p *KrokHandler) Send(req *http.Request, parseTo interface{}) (*http.Respon
 			p.Logger.Debug().Err(err).Msg("Failed to close response body reader.")
 		}
 	}()
-	if parseTo != nil {
-		if err := p.parseBody(resp.Body, parseTo); err != nil {
+	p.Logger.Debug().
+		Str("method", req.Method).
+		Str("path", req.URL.Path).
+		Int("status", resp.StatusCode).
+		Dur("duration", time.Since(start)).
+		Dur("queued", start.Sub(queued)).
+		Str("request_id", requestID).
+		Msg("Request completed.")
+	// error responses don't contain the expected output, the caller deals with the status code.
+	if parseTo != nil && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		body := io.Reader(resp.Body)
+		if e := p.Logger.Trace(); e.Enabled() {
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return nil, err
+			}
+			e.Str("request_id", requestID).Str("payload", redact(b)).Msg("Response payload.")
+			body = bytes.NewReader(b)
+		}
+		if err := p.parseBody(body, parseTo); err != nil {
 			return nil, err
 		}
 	}
 	return resp, nil
 }
 
+// wait blocks until a request can be sent without exceeding the rate limit or the number of requests in
+// flight. Once the request is done, done has to be called.
+func (p *KrokHandler) wait(ctx context.Context) error {
+	if p.inFlight != nil {
+		select {
+		case p.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for a request to finish: %w", ctx.Err())
+		}
+	}
+	if p.limiter != nil {
+		if err := p.limiter.Wait(ctx); err != nil {
+			p.done()
+			return fmt.Errorf("waiting for the rate limit: %w", err)
+		}
+	}
+	return nil
+}
+
+// done releases the slot of a request taken by wait.
+func (p *KrokHandler) done() {
+	if p.inFlight != nil {
+		<-p.inFlight
+	}
+}
+
+// newRequestID generates a random ID which is sent along with a request so it can be
+// correlated with the server logs.
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
 // parseBody is a convenient wrapper around a common set of logical operations to get something out of
 // the return body of an http response.
 func (p *KrokHandler) parseBody(respBody io.Reader, v interface{}) error {
@@ -189,3 +496,22 @@ func (p *KrokHandler) parseBody(respBody io.Reader, v interface{}) error {
 	}
 	return nil
 }
+
+// routeTemplate replaces the IDs in the path of a request URL so the route can be used as a span name.
+// The API version segment is kept as it is.
+func routeTemplate(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, s := range segments {
+		if s == "" || (i > 0 && segments[i-1] == "api") {
+			continue
+		}
+		if _, err := strconv.Atoi(s); err == nil {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
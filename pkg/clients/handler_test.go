@@ -0,0 +1,659 @@
+package clients
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMakeRequestTracing(t *testing.T) {
+	var (
+		traceParents []string
+		tokens       int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParents = append(traceParents, r.Header.Get("traceparent"))
+		switch r.URL.Path {
+		case getTokenURI:
+			tokens++
+			_, _ = w.Write([]byte(`{"Token":"token"}`))
+		case "/rest/api/1/krok/command/12":
+			// reject the first token to force a retry.
+			if tokens == 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":12}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	handler := NewHandler(Config{
+		Client:         server.Client(),
+		Address:        server.URL,
+		Logger:         zerolog.Nop(),
+		TracerProvider: tp,
+	})
+	// prime the token cache so the first request is sent with a token the server rejects.
+	if _, err := handler.MakeRequest(context.Background(), http.MethodGet, server.URL+"/rest/api/1/krok/command/12"); err != nil {
+		t.Fatal(err)
+	}
+	exporter.Reset()
+
+	var out map[string]interface{}
+	code, err := handler.MakeRequest(context.Background(), http.MethodGet, server.URL+"/rest/api/1/krok/command/12", WithOutput(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected an authenticate and a request span, got %d", len(spans))
+	}
+	auth, request := spans[0], spans[1]
+	if auth.Name != "krok.authenticate" {
+		t.Errorf("unexpected span name %q", auth.Name)
+	}
+	if auth.Parent.SpanID() != request.SpanContext.SpanID() {
+		t.Error("authenticate span isn't a child of the request span")
+	}
+	if request.Name != "GET /rest/api/1/krok/command/{id}" {
+		t.Errorf("unexpected span name %q", request.Name)
+	}
+	attrs := attribute.NewSet(request.Attributes...)
+	if v, _ := attrs.Value("http.response.status_code"); v.AsInt64() != http.StatusOK {
+		t.Errorf("unexpected status attribute %v", v.Emit())
+	}
+	if v, _ := attrs.Value("krok.retries"); v.AsInt64() != 1 {
+		t.Errorf("unexpected retries attribute %v", v.Emit())
+	}
+	for _, tp := range traceParents[len(traceParents)-3:] {
+		if tp == "" {
+			t.Fatal("traceparent header wasn't propagated")
+		}
+	}
+}
+
+func TestMakeRequestWithBodyIsResentOnRetry(t *testing.T) {
+	var (
+		bodies []string
+		tokens int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case getTokenURI:
+			tokens++
+			_, _ = w.Write([]byte(`{"Token":"token"}`))
+		case "/rest/api/1/krok/command":
+			b, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(b))
+			// reject the first token to force a retry.
+			if tokens == 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":12}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	handler := NewHandler(Config{
+		Client:  server.Client(),
+		Address: server.URL,
+		Logger:  zerolog.Nop(),
+	})
+	// prime the token cache so the first upload is sent with a token the server rejects.
+	if _, err := handler.MakeRequest(context.Background(), http.MethodGet, server.URL+"/rest/api/1/krok/commands"); err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	body := func() (io.ReadCloser, error) {
+		calls++
+		return io.NopCloser(strings.NewReader("archive")), nil
+	}
+	code, err := handler.MakeRequest(context.Background(), http.MethodPut, server.URL+"/rest/api/1/krok/command", WithBody(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if calls != 2 {
+		t.Fatalf("expected the body to be created for both attempts, got %d", calls)
+	}
+	if len(bodies) != 2 || bodies[0] != "archive" || bodies[1] != "archive" {
+		t.Fatalf("expected the full body to be sent twice, got %q", bodies)
+	}
+}
+
+func TestMakeRequestDoesNotParseErrorResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case getTokenURI:
+			_, _ = w.Write([]byte(`{"Token":"token"}`))
+		case "/rest/api/1/krok/commands":
+			// Krok describes errors with an object, which doesn't fit the list the caller expects.
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"code":404,"message":"commands not found"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	handler := NewHandler(Config{
+		Client:  server.Client(),
+		Address: server.URL,
+		Logger:  zerolog.Nop(),
+	})
+
+	var commands []struct{ ID int }
+	code, err := handler.MakeRequest(context.Background(), http.MethodPost, server.URL+"/rest/api/1/krok/commands", WithOutput(&commands))
+	if err != nil || code != http.StatusNotFound {
+		t.Fatalf("expected status 404 without an error, got %d, %v", code, err)
+	}
+	var command struct{ ID int }
+	code, err = handler.MakeRequest(context.Background(), http.MethodGet, server.URL+"/rest/api/1/krok/command/12", WithOutput(&command))
+	if err != nil || code != http.StatusNotFound {
+		t.Fatalf("expected status 404 without an error for an empty body, got %d, %v", code, err)
+	}
+	if commands != nil || command.ID != 0 {
+		t.Fatalf("expected the outputs to be left alone, got %v and %v", commands, command)
+	}
+}
+
+func TestMakeRequestRetriesRejectedToken(t *testing.T) {
+	var (
+		tokens   int
+		requests []string
+		// accepted is the first token the server accepts.
+		accepted int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == getTokenURI {
+			tokens++
+			_, _ = fmt.Fprintf(w, `{"Token":"token-%d"}`, tokens)
+			return
+		}
+		requests = append(requests, r.Header.Get("Authorization"))
+		var n int
+		if _, err := fmt.Sscanf(r.Header.Get("Authorization"), "Bearer token-%d", &n); err != nil || accepted == 0 || n < accepted {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":12}`))
+	}))
+	defer server.Close()
+	handler := NewHandler(Config{
+		Client:  server.Client(),
+		Address: server.URL,
+		Logger:  zerolog.Nop(),
+	})
+	request := func() int {
+		t.Helper()
+		requests = nil
+		code, err := handler.MakeRequest(context.Background(), http.MethodGet, server.URL+"/rest/api/1/krok/command/12")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	// a token which has just been fetched isn't fetched again.
+	if code := request(); code != http.StatusUnauthorized || len(requests) != 1 || tokens != 1 {
+		t.Fatalf("expected a single rejected request, got %d after %v with %d tokens", code, requests, tokens)
+	}
+	// the cached token expired, so a new one is fetched.
+	accepted = 2
+	if code := request(); code != http.StatusOK || strings.Join(requests, ",") != "Bearer token-1,Bearer token-2" {
+		t.Fatalf("expected the request to be retried with a new token, got %d after %v", code, requests)
+	}
+	// the new token is used from now on.
+	if code := request(); code != http.StatusOK || strings.Join(requests, ",") != "Bearer token-2" {
+		t.Fatalf("expected the new token to be cached, got %d after %v", code, requests)
+	}
+	// a rejected new token isn't retried again.
+	accepted = 10
+	if code := request(); code != http.StatusUnauthorized || strings.Join(requests, ",") != "Bearer token-2,Bearer token-3" {
+		t.Fatalf("expected a single retry, got %d after %v", code, requests)
+	}
+}
+
+func TestMakeRequestRereadsTokenFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == getTokenURI {
+			t.Error("the API key exchange must not be used with a token file")
+		}
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":12}`))
+	}))
+	defer server.Close()
+
+	handler := NewHandler(Config{
+		Client:      server.Client(),
+		Address:     server.URL,
+		Logger:      zerolog.Nop(),
+		TokenSource: TokenFile(file),
+	})
+	if code, err := handler.MakeRequest(context.Background(), http.MethodGet, server.URL+"/rest/api/1/krok/command/12"); err != nil || code != http.StatusUnauthorized {
+		t.Fatalf("expected the old token to be rejected, got %d, %v", code, err)
+	}
+	// the token expires and is replaced by another process.
+	if err := os.WriteFile(file, []byte("new\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	code, err := handler.MakeRequest(context.Background(), http.MethodGet, server.URL+"/rest/api/1/krok/command/12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	want := []string{"Bearer old", "Bearer old", "Bearer new"}
+	if strings.Join(authorizations, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected authorizations %v, got %v", want, authorizations)
+	}
+}
+
+func TestTokenCommand(t *testing.T) {
+	token, err := TokenCommand("echo ' token '").Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token" {
+		t.Fatalf("expected token, got %q", token)
+	}
+	if _, err := TokenCommand("echo failed >&2; exit 1").Token(context.Background()); !errors.Is(err, ErrAuthentication) || !strings.Contains(err.Error(), "failed") {
+		t.Fatalf("expected an authentication error with the output of the command, got %v", err)
+	}
+}
+
+// countingTokenSource returns token and counts how often it's asked for one.
+type countingTokenSource struct {
+	token string
+	calls int
+}
+
+func (s *countingTokenSource) Token(context.Context) (string, error) {
+	s.calls++
+	return s.token, nil
+}
+
+// jwt returns an unsigned JWT expiring at exp.
+func jwt(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	return "eyJhbGciOiJIUzI1NiJ9." + payload + ".signature"
+}
+
+func TestFileTokenCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tokens")
+	now := time.Now()
+	source := &countingTokenSource{token: jwt(now.Add(time.Hour))}
+	newCache := func() *FileTokenCache {
+		return &FileTokenCache{
+			Source: source,
+			Dir:    dir,
+			Key:    TokenCacheKey("http://krok", "admin@example.com", "key"),
+			Logger: zerolog.Nop(),
+			Now:    func() time.Time { return now },
+		}
+	}
+	// a second cache stands in for another Terraform process.
+	for _, c := range []*FileTokenCache{newCache(), newCache()} {
+		token, err := c.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != source.token {
+			t.Fatalf("expected %q, got %q", source.token, token)
+		}
+	}
+	if source.calls != 1 {
+		t.Fatalf("expected the token to be fetched once, got %d", source.calls)
+	}
+	info, err := os.Stat(newCache().path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the cache file to have mode 0600, got %o", info.Mode().Perm())
+	}
+
+	// tokens which are about to expire are replaced.
+	now = now.Add(time.Hour - tokenExpiryMargin)
+	source.token = jwt(now.Add(time.Hour))
+	if token, _ := newCache().Token(context.Background()); token != source.token || source.calls != 2 {
+		t.Fatalf("expected a new token, got %q after %d calls", token, source.calls)
+	}
+
+	// a rejected token isn't used again.
+	newCache().Invalidate(source.token)
+	if _, err := os.Stat(newCache().path()); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the cache file to be removed, got %v", err)
+	}
+
+	// other credentials have their own cache file.
+	other := newCache()
+	other.Key = TokenCacheKey("http://krok", "admin@example.com", "other")
+	if other.path() == newCache().path() {
+		t.Fatal("expected different credentials to use different cache files")
+	}
+}
+
+func TestFileTokenCacheIgnoresUnsafeFiles(t *testing.T) {
+	dir := t.TempDir()
+	source := &countingTokenSource{token: jwt(time.Now().Add(time.Hour))}
+	cache := &FileTokenCache{Source: source, Dir: dir, Key: "key", Logger: zerolog.Nop()}
+	if _, err := cache.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(cache.path(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if source.calls != 2 {
+		t.Fatalf("expected a readable cache file to be ignored, got %d calls", source.calls)
+	}
+
+	// tokens without an expiry aren't cached.
+	source.token = "opaque"
+	cache.Key = "opaque"
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Token(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if source.calls != 4 {
+		t.Fatalf("expected tokens without expiry not to be cached, got %d calls", source.calls)
+	}
+}
+
+func TestMakeRequestLimitsConcurrentRequests(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"id":12}`))
+	}))
+	defer server.Close()
+
+	handler := NewHandler(Config{
+		Client:                server.Client(),
+		Address:               server.URL,
+		Logger:                zerolog.Nop(),
+		TokenSource:           StaticToken("token"),
+		MaxConcurrentRequests: 3,
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code, err := handler.MakeRequest(context.Background(), http.MethodGet, server.URL+"/rest/api/1/krok/command/12"); err != nil || code != http.StatusOK {
+				t.Errorf("expected status 200, got %d, %v", code, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if m := maxInFlight.Load(); m != 3 {
+		t.Fatalf("expected at most 3 requests in flight and the limit to be used, got %d", m)
+	}
+}
+
+func TestMakeRequestLimitsRate(t *testing.T) {
+	var (
+		lock  sync.Mutex
+		times []time.Time
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		times = append(times, time.Now())
+		lock.Unlock()
+		_, _ = w.Write([]byte(`{"id":12}`))
+	}))
+	defer server.Close()
+
+	handler := NewHandler(Config{
+		Client:            server.Client(),
+		Address:           server.URL,
+		Logger:            zerolog.Nop(),
+		TokenSource:       StaticToken("token"),
+		RequestsPerSecond: 50,
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code, err := handler.MakeRequest(context.Background(), http.MethodGet, server.URL+"/rest/api/1/krok/command/12"); err != nil || code != http.StatusOK {
+				t.Errorf("expected status 200, got %d, %v", code, err)
+			}
+		}()
+	}
+	wg.Wait()
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	// 10 requests at 50 per second take at least 9 intervals of 20ms.
+	if d := times[len(times)-1].Sub(times[0]); d < 170*time.Millisecond {
+		t.Fatalf("expected the requests to be spread over at least 170ms, got %s", d)
+	}
+
+	// a request which can't be sent before the deadline fails without reaching the server.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	handler = NewHandler(Config{
+		Client:            server.Client(),
+		Address:           server.URL,
+		Logger:            zerolog.Nop(),
+		TokenSource:       StaticToken("token"),
+		RequestsPerSecond: 0.1,
+	})
+	if _, err := handler.MakeRequest(context.Background(), http.MethodGet, server.URL+"/rest/api/1/krok/command/12"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handler.MakeRequest(ctx, http.MethodGet, server.URL+"/rest/api/1/krok/command/12"); err == nil || !strings.Contains(err.Error(), "rate limit") {
+		t.Fatalf("expected the rate limit to fail the request, got %v", err)
+	}
+	if len(times) != 11 {
+		t.Fatalf("expected 11 requests to reach the server, got %d", len(times))
+	}
+}
+
+func TestMakeRequestCachesReads(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.Method+" "+r.URL.Path]++
+		if r.URL.Path == "/rest/api/1/krok/command/404" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"id":%d}`, requests[r.Method+" "+r.URL.Path])
+	}))
+	defer server.Close()
+
+	handler := NewHandler(Config{
+		Client:      server.Client(),
+		Address:     server.URL,
+		Logger:      zerolog.Nop(),
+		TokenSource: StaticToken("token"),
+		CacheReads:  true,
+	})
+	get := func(path string) (int, int) {
+		t.Helper()
+		var result struct {
+			ID int `json:"id"`
+		}
+		code, err := handler.MakeRequest(context.Background(), http.MethodGet, server.URL+path, WithOutput(&result))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code, result.ID
+	}
+	for _, path := range []string{"/rest/api/1/krok/command/12", "/rest/api/1/krok/repository/3", "/supported-platforms"} {
+		for i := 0; i < 2; i++ {
+			if code, id := get(path); code != http.StatusOK || id != 1 {
+				t.Fatalf("expected the first response of %s, got %d, %d", path, code, id)
+			}
+		}
+	}
+	// attaching a command changes both the command and the repository.
+	if _, err := handler.MakeRequest(context.Background(), http.MethodPost, server.URL+"/rest/api/1/krok/command/add-command-rel-for-repository/12/3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, id := get("/rest/api/1/krok/command/12"); id != 2 {
+		t.Fatalf("expected the command to be fetched again, got %d", id)
+	}
+	if _, id := get("/rest/api/1/krok/repository/3"); id != 2 {
+		t.Fatalf("expected the repository to be fetched again, got %d", id)
+	}
+	// errors and other routes aren't cached.
+	for i := 0; i < 2; i++ {
+		if code, _ := get("/rest/api/1/krok/command/404"); code != http.StatusNotFound {
+			t.Fatalf("expected status 404, got %d", code)
+		}
+		get("/rest/api/1/krok/user/12")
+	}
+	if requests["GET /rest/api/1/krok/command/404"] != 2 || requests["GET /rest/api/1/krok/user/12"] != 2 {
+		t.Fatalf("expected uncached requests to reach the server every time, got %v", requests)
+	}
+	if requests["GET /supported-platforms"] != 1 {
+		t.Fatalf("expected platforms to be fetched once, got %v", requests)
+	}
+}
+
+func TestInvalidatedKinds(t *testing.T) {
+	for url, want := range map[string]string{
+		"http://krok/rest/api/1/krok/command":                                     "command,repository,platform",
+		"http://krok/rest/api/1/krok/command/update":                              "command,repository,platform",
+		"http://krok/rest/api/1/krok/command/12":                                  "command,repository,platform",
+		"http://krok/rest/api/1/krok/command/remove-command-rel-for-platform/1/2": "command,repository,platform",
+		"http://krok/rest/api/1/krok/repository/update":                           "repository,command",
+		"http://krok/rest/api/1/krok/command/setting":                             "",
+		"http://krok/rest/api/1/krok/command/run/12":                              "",
+		"http://krok/rest/api/1/krok/vault/secret":                                "",
+	} {
+		if got := strings.Join(invalidatedKinds(url), ","); got != want {
+			t.Errorf("%s: expected %q, got %q", url, want, got)
+		}
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := WithTimeout(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > DefaultTimeout {
+		t.Fatalf("expected a deadline within %s, got %v", DefaultTimeout, deadline)
+	}
+
+	// the deadline of an operation replaces the default.
+	operation, cancelOperation := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelOperation()
+	ctx, cancel = WithTimeout(operation)
+	defer cancel()
+	if got, _ := ctx.Deadline(); time.Until(got) < time.Minute {
+		t.Fatalf("expected the deadline of the operation, got %v", got)
+	}
+}
+
+func TestMakeRequestDoesNotCacheReadsOverlappingWrites(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		version = "old"
+		gets    int
+	)
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			lock.Lock()
+			version = "new"
+			lock.Unlock()
+			return
+		}
+		lock.Lock()
+		current := version
+		gets++
+		first := gets == 1
+		lock.Unlock()
+		if first {
+			// the first read is answered before the write, but only arrives after it.
+			close(started)
+			<-release
+		}
+		_, _ = fmt.Fprintf(w, `{"name":%q}`, current)
+	}))
+	defer server.Close()
+
+	handler := NewHandler(Config{
+		Client:      server.Client(),
+		Address:     server.URL,
+		Logger:      zerolog.Nop(),
+		TokenSource: StaticToken("token"),
+		CacheReads:  true,
+	})
+	get := func() string {
+		var result struct {
+			Name string `json:"name"`
+		}
+		if _, err := handler.MakeRequest(context.Background(), http.MethodGet, server.URL+"/rest/api/1/krok/command/12", WithOutput(&result)); err != nil {
+			t.Error(err)
+		}
+		return result.Name
+	}
+	done := make(chan string)
+	go func() { done <- get() }()
+	<-started
+	if _, err := handler.MakeRequest(context.Background(), http.MethodPost, server.URL+"/rest/api/1/krok/command/update"); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if name := <-done; name != "old" {
+		t.Fatalf("expected the overlapping read to return the old command, got %q", name)
+	}
+	if name := get(); name != "new" {
+		t.Fatalf("expected the overlapping read not to be cached, got %q", name)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"net/url"
 	"path"
-	"time"
 
 	"github.com/krok-o/krok/pkg/models"
 	"github.com/rs/zerolog"
@@ -14,11 +13,7 @@ import (
 	"github.com/krok-o/terraform-provider-krok/pkg/clients"
 )
 
-const (
-	timeOutInSeconds = 10
-	platformURIs     = "/supported-platforms"
-	platformURI      = "/supported-platform"
-)
+const platformURIs = "/supported-platforms"
 
 // NewClient creates a new platform provider.
 func NewClient(address string, log zerolog.Logger, handler clients.Handler) *Client {
@@ -37,8 +32,8 @@ type Client struct {
 }
 
 // List platforms.
-func (c *Client) List() ([]models.Platform, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) List(ctx context.Context) ([]models.Platform, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -61,27 +56,18 @@ func (c *Client) List() ([]models.Platform, error) {
 	return result, nil
 }
 
-// Get platform.
-func (c *Client) Get(id int) (*models.Platform, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
-	defer cancel()
-
-	u, err := url.Parse(c.Address)
+// Get returns the platform with the given id. Krok has no endpoint for a single platform, so it is
+// looked up in the list of supported platforms.
+func (c *Client) Get(ctx context.Context, id int) (*models.Platform, error) {
+	platforms, err := c.List(ctx)
 	if err != nil {
-		c.Logger.Debug().Err(err).Msg("Failed to parse address")
 		return nil, err
 	}
-
-	var result *models.Platform
-	u.Path = path.Join(u.Path, platformURI, fmt.Sprintf("%d", id))
-	code, err := c.Handler.MakeRequest(ctx, http.MethodGet, u.String(), clients.WithOutput(&result))
-	if err != nil {
-		c.Logger.Debug().Err(err).Int("code", code).Msg("Failed to get result.")
-		return nil, err
+	for _, p := range platforms {
+		if p.ID == id {
+			return &p, nil
+		}
 	}
-	if code > 299 || code < 200 {
-		c.Logger.Error().Str("url", u.String()).Int("code", code).Msg("Return code was not OK")
-		return nil, fmt.Errorf("return code was not OK %d", code)
-	}
-	return result, nil
+	c.Logger.Debug().Int("id", id).Msg("Platform not found.")
+	return nil, fmt.Errorf("platform %d is not supported by the server", id)
 }
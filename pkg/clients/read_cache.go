@@ -0,0 +1,121 @@
+package clients
+
+import (
+	"net/url"
+	"regexp"
+	"sync"
+)
+
+// cachedRoutes maps the routes whose responses are cached to the kind of resource they return.
+var cachedRoutes = []struct {
+	kind  string
+	route *regexp.Regexp
+}{
+	{kind: "command", route: regexp.MustCompile(`^/rest/api/1/krok/command/\d+$`)},
+	{kind: "platform", route: regexp.MustCompile(`^/supported-platforms$`)},
+	{kind: "repository", route: regexp.MustCompile(`^/rest/api/1/krok/repository/\d+$`)},
+}
+
+// writeRoutes maps the routes changing a kind of resource to the kinds whose cached responses are
+// invalidated by them. Commands and repositories embed each other, and commands embed platforms, so a
+// relationship change shows up on both sides.
+var writeRoutes = []struct {
+	kinds []string
+	route *regexp.Regexp
+}{
+	{kinds: []string{"command", "repository", "platform"}, route: regexp.MustCompile(`^/rest/api/1/krok/command(/(update|add-.*|remove-.*|\d+))?$`)},
+	{kinds: []string{"repository", "command"}, route: regexp.MustCompile(`^/rest/api/1/krok/repository(/.*)?$`)},
+}
+
+// readCache holds the responses of reads of commands, platforms and repositories for the lifetime of the
+// handler, so the same object isn't fetched over and over during a Terraform run.
+type readCache struct {
+	lock    sync.Mutex
+	entries map[string]cacheEntry
+	// generations counts the invalidations of each kind, so a read which overlapped a write isn't cached.
+	generations map[string]uint64
+	hits        int
+	misses      int
+}
+
+// cacheEntry is a cached response body.
+type cacheEntry struct {
+	kind string
+	body []byte
+}
+
+// newReadCache creates an empty cache.
+func newReadCache() *readCache {
+	return &readCache{entries: make(map[string]cacheEntry), generations: make(map[string]uint64)}
+}
+
+// cacheKind returns the kind of resource a cacheable read of rawURL returns, or "" if it isn't cached.
+func cacheKind(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	for _, r := range cachedRoutes {
+		if r.route.MatchString(u.Path) {
+			return r.kind
+		}
+	}
+	return ""
+}
+
+// invalidatedKinds returns the kinds of resources a write to rawURL can change.
+func invalidatedKinds(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	for _, r := range writeRoutes {
+		if r.route.MatchString(u.Path) {
+			return r.kinds
+		}
+	}
+	return nil
+}
+
+// get returns the cached body for rawURL, the current generation of kind and the number of hits and
+// misses so far.
+func (c *readCache) get(rawURL, kind string) ([]byte, bool, uint64, int, int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.entries[rawURL]
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return e.body, ok, c.generations[kind], c.hits, c.misses
+}
+
+// put caches the body of a response, unless kind has been invalidated since generation was returned by
+// get. The response might predate the write which invalidated it then.
+func (c *readCache) put(rawURL, kind string, generation uint64, body []byte) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.generations[kind] != generation {
+		return false
+	}
+	c.entries[rawURL] = cacheEntry{kind: kind, body: body}
+	return true
+}
+
+// invalidate removes the cached responses of the given kinds.
+func (c *readCache) invalidate(kinds []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, k := range kinds {
+		c.generations[k]++
+	}
+	for key, e := range c.entries {
+		for _, k := range kinds {
+			if e.kind == k {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are JSON keys whose values are never written to the logs.
+var sensitiveKeys = map[string]struct{}{
+	"api_key_secret": {},
+	"secret":         {},
+	"token":          {},
+	"password":       {},
+	"ssh":            {},
+	"authorization":  {},
+}
+
+// redact returns a copy of a JSON payload with all sensitive values replaced. Vault values, meaning
+// any `value` which isn't explicitly marked with `in_vault: false`, are redacted too.
+// Payloads which aren't JSON are replaced entirely since they can't be inspected.
+func redact(payload []byte) string {
+	if len(payload) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return redacted
+	}
+	b, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return redacted
+	}
+	return string(b)
+}
+
+// redactValue walks a decoded JSON value and replaces sensitive fields.
+func redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		inVault, ok := t["in_vault"].(bool)
+		keepValue := ok && !inVault
+		for k, e := range t {
+			if _, sensitive := sensitiveKeys[strings.ToLower(k)]; sensitive {
+				t[k] = redacted
+				continue
+			}
+			if strings.ToLower(k) == "value" && !keepValue {
+				t[k] = redacted
+				continue
+			}
+			t[k] = redactValue(e)
+		}
+		return t
+	case []interface{}:
+		for i, e := range t {
+			t[i] = redactValue(e)
+		}
+		return t
+	default:
+		return v
+	}
+}
@@ -0,0 +1,53 @@
+package clients
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{name: "empty", payload: "", want: ""},
+		{name: "not json", payload: "token=secret", want: redacted},
+		{name: "api key secret", payload: `{"api_key_secret":"s","api_key_id":"id"}`, want: `{"api_key_id":"id","api_key_secret":"[REDACTED]"}`},
+		{name: "secret", payload: `{"secret":"s","name":"repo"}`, want: `{"name":"repo","secret":"[REDACTED]"}`},
+		{name: "token", payload: `{"token":"t","vcs":1}`, want: `{"token":"[REDACTED]","vcs":1}`},
+		{name: "password", payload: `{"password":"p"}`, want: `{"password":"[REDACTED]"}`},
+		{name: "ssh", payload: `{"ssh":"key"}`, want: `{"ssh":"[REDACTED]"}`},
+		{name: "authorization", payload: `{"Authorization":"Bearer t"}`, want: `{"Authorization":"[REDACTED]"}`},
+		{name: "value in vault", payload: `{"key":"k","value":"v","in_vault":true}`, want: `{"in_vault":true,"key":"k","value":"[REDACTED]"}`},
+		{name: "value not in vault", payload: `{"key":"k","value":"v","in_vault":false}`, want: `{"in_vault":false,"key":"k","value":"v"}`},
+		{name: "value without in_vault", payload: `{"key":"k","value":"v"}`, want: `{"key":"k","value":"[REDACTED]"}`},
+		{
+			name:    "nested objects",
+			payload: `{"name":"repo","auth":{"secret":"s"},"commands":[{"name":"c","settings":[{"value":"v","in_vault":false},{"value":"v","in_vault":true}]}]}`,
+			want:    `{"auth":{"secret":"[REDACTED]"},"commands":[{"name":"c","settings":[{"in_vault":false,"value":"v"},{"in_vault":true,"value":"[REDACTED]"}]}],"name":"repo"}`,
+		},
+		{name: "list of objects", payload: `[{"token":"t"},{"id":1}]`, want: `[{"token":"[REDACTED]"},{"id":1}]`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := redact([]byte(tc.payload))
+			if tc.want == "" || tc.want == redacted {
+				if got != tc.want {
+					t.Fatalf("expected %q, got %q", tc.want, got)
+				}
+				return
+			}
+			var gotValue, wantValue interface{}
+			if err := json.Unmarshal([]byte(got), &gotValue); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.want), &wantValue); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
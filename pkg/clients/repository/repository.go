@@ -8,7 +8,6 @@ import (
 	"net/url"
 	"path"
 	"strconv"
-	"time"
 
 	"github.com/rs/zerolog"
 
@@ -18,9 +17,8 @@ import (
 )
 
 const (
-	timeOutInSeconds = 10
-	repositoryURI    = "/rest/api/1/krok/repository"
-	repositoriesURI  = "/rest/api/1/krok/repositories"
+	repositoryURI   = "/rest/api/1/krok/repository"
+	repositoriesURI = "/rest/api/1/krok/repositories"
 )
 
 // NewClient creates a new repository provider.
@@ -40,8 +38,8 @@ type Client struct {
 }
 
 // Create creates a repository resource.
-func (c *Client) Create(repo *models.Repository) (*models.Repository, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Create(ctx context.Context, repo *models.Repository) (*models.Repository, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(repo)
@@ -71,8 +69,8 @@ func (c *Client) Create(repo *models.Repository) (*models.Repository, error) {
 }
 
 // Update updates a repository resource.
-func (c *Client) Update(repo *models.Repository) (*models.Repository, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Update(ctx context.Context, repo *models.Repository) (*models.Repository, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(repo)
@@ -102,8 +100,8 @@ func (c *Client) Update(repo *models.Repository) (*models.Repository, error) {
 }
 
 // Delete deletes a repository resource.
-func (c *Client) Delete(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Delete(ctx context.Context, id int) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -125,8 +123,8 @@ func (c *Client) Delete(id int) error {
 }
 
 // List repositories.
-func (c *Client) List(opts *models.ListOptions) ([]*models.Repository, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) List(ctx context.Context, opts *models.ListOptions) ([]*models.Repository, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(opts)
@@ -156,8 +154,8 @@ func (c *Client) List(opts *models.ListOptions) ([]*models.Repository, error) {
 }
 
 // Get returns a repository resource.
-func (c *Client) Get(id int) (*models.Repository, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Get(ctx context.Context, id int) (*models.Repository, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -173,6 +171,9 @@ func (c *Client) Get(id int) (*models.Repository, error) {
 		c.Logger.Debug().Err(err).Int("code", code).Msg("Failed to get result.")
 		return nil, err
 	}
+	if code == http.StatusNotFound {
+		return nil, fmt.Errorf("repository %d %w, return code was not OK %d", id, clients.ErrNotFound, code)
+	}
 	if code > 299 || code < 200 {
 		c.Logger.Error().Str("url", u.String()).Int("code", code).Msg("Return code was not OK")
 		return nil, fmt.Errorf("return code was not OK %d", code)
@@ -7,7 +7,6 @@ import (
 	"net/url"
 	"path"
 	"strconv"
-	"time"
 
 	"github.com/rs/zerolog"
 
@@ -17,8 +16,7 @@ import (
 )
 
 const (
-	timeOutInSeconds = 10
-	runURI           = "/rest/api/1/krok/command/run"
+	runURI = "/rest/api/1/krok/command/run"
 )
 
 // NewClient creates a new command run provider.
@@ -38,8 +36,8 @@ type Client struct {
 }
 
 // Get returns a command resource.
-func (c *Client) Get(id int) (*models.CommandRun, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Get(ctx context.Context, id int) (*models.CommandRun, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
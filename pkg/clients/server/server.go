@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/terraform-provider-krok/pkg/clients"
+)
+
+const healthURI = "/healthz"
+
+// NewClient creates a new server provider.
+func NewClient(address string, log zerolog.Logger, handler clients.Handler) *Client {
+	return &Client{
+		Address: address,
+		Logger:  log,
+		Handler: handler,
+	}
+}
+
+// Client contains methods to check the state of the Krok server itself.
+type Client struct {
+	Address string
+	Logger  zerolog.Logger
+	Handler clients.Handler
+}
+
+// Health checks whether the server is alive. This doesn't require authentication.
+func (c *Client) Health(ctx context.Context) (int, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
+	defer cancel()
+
+	u, err := url.Parse(c.Address)
+	if err != nil {
+		c.Logger.Debug().Err(err).Msg("Failed to parse address")
+		return 0, err
+	}
+
+	u.Path = path.Join(u.Path, healthURI)
+	code, err := c.Handler.MakeRequest(ctx, http.MethodGet, u.String(), clients.WithoutAuthentication())
+	if err != nil {
+		c.Logger.Debug().Err(err).Int("code", code).Msg("Failed to get result.")
+		return 0, err
+	}
+	if code > 299 || code < 200 {
+		c.Logger.Error().Str("url", u.String()).Int("code", code).Msg("Return code was not OK")
+		return code, fmt.Errorf("return code was not OK %d", code)
+	}
+	return code, nil
+}
+
+// Authenticate verifies the configured credentials by requesting a new token.
+func (c *Client) Authenticate(ctx context.Context) error {
+	ctx, cancel := clients.WithTimeout(ctx)
+	defer cancel()
+
+	if err := c.Handler.Authenticate(ctx); err != nil {
+		c.Logger.Debug().Err(err).Msg("Failed to authenticate.")
+		return err
+	}
+	return nil
+}
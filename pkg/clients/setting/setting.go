@@ -8,7 +8,6 @@ import (
 	"net/url"
 	"path"
 	"strconv"
-	"time"
 
 	"github.com/rs/zerolog"
 
@@ -18,10 +17,9 @@ import (
 )
 
 const (
-	timeOutInSeconds = 10
-	settingURI       = "/rest/api/1/krok/command/setting"
-	settingsURI      = "/rest/api/1/krok/command/settings"
-	listURI          = "/rest/api/1/krok/command"
+	settingURI  = "/rest/api/1/krok/command/setting"
+	settingsURI = "/rest/api/1/krok/command/settings"
+	listURI     = "/rest/api/1/krok/command"
 )
 
 // NewClient creates a new settings provider.
@@ -41,8 +39,8 @@ type Client struct {
 }
 
 // Create will create settings.
-func (c *Client) Create(setting *models.CommandSetting) (*models.CommandSetting, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Create(ctx context.Context, setting *models.CommandSetting) (*models.CommandSetting, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(setting)
@@ -72,8 +70,8 @@ func (c *Client) Create(setting *models.CommandSetting) (*models.CommandSetting,
 }
 
 // Update will update a setting.
-func (c *Client) Update(setting *models.CommandSetting) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Update(ctx context.Context, setting *models.CommandSetting) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(setting)
@@ -102,8 +100,8 @@ func (c *Client) Update(setting *models.CommandSetting) error {
 }
 
 // List settings.
-func (c *Client) List(id int) ([]*models.CommandSetting, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) List(ctx context.Context, id int) ([]*models.CommandSetting, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -127,8 +125,8 @@ func (c *Client) List(id int) ([]*models.CommandSetting, error) {
 }
 
 // Get returns a setting resource.
-func (c *Client) Get(id int) (*models.CommandSetting, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Get(ctx context.Context, id int) (*models.CommandSetting, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -152,8 +150,8 @@ func (c *Client) Get(id int) (*models.CommandSetting, error) {
 }
 
 // Delete the selected setting.
-func (c *Client) Delete(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Delete(ctx context.Context, id int) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -0,0 +1,169 @@
+package clients
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// tokenExpiryMargin is how long before it expires a cached token is no longer used, so it
+// doesn't expire while requests are made with it.
+const tokenExpiryMargin = time.Minute
+
+// TokenInvalidator is implemented by token sources which cache tokens themselves. The handler
+// calls Invalidate with a token the server rejected.
+type TokenInvalidator interface {
+	Invalidate(token string)
+}
+
+// FileTokenCache persists the tokens of Source in Dir, so the Terraform processes started for a
+// plan or apply don't all fetch their own token. Tokens are only cached if they are JWTs with an
+// expiry, and are used until shortly before they expire.
+type FileTokenCache struct {
+	Source TokenSource
+	Dir    string
+	// Key identifies the credentials of Source, see TokenCacheKey.
+	Key    string
+	Logger zerolog.Logger
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// cachedToken is the content of a cache file.
+type cachedToken struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// TokenCacheKey returns the cache key for the credentials of a Krok server.
+func TokenCacheKey(address, email, apiKeyID string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{address, email, apiKeyID}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Token implements TokenSource. It returns the cached token if it's still valid, and caches a new token otherwise.
+func (c *FileTokenCache) Token(ctx context.Context) (string, error) {
+	if token, ok := c.load(); ok {
+		c.Logger.Debug().Msg("Using cached token.")
+		return token, nil
+	}
+	token, err := c.Source.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	expires, ok := tokenExpiry(token)
+	if !ok {
+		c.Logger.Debug().Msg("Token has no expiry, not caching it.")
+		return token, nil
+	}
+	if err := c.store(cachedToken{Token: token, Expires: expires}); err != nil {
+		c.Logger.Debug().Err(err).Msg("Failed to cache token.")
+	}
+	return token, nil
+}
+
+// Invalidate implements TokenInvalidator. The cache file is removed if it still holds token.
+func (c *FileTokenCache) Invalidate(token string) {
+	if cached, ok := c.load(); ok && cached == token {
+		if err := os.Remove(c.path()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			c.Logger.Debug().Err(err).Msg("Failed to remove cached token.")
+		}
+	}
+}
+
+// path returns the path of the cache file.
+func (c *FileTokenCache) path() string {
+	return filepath.Join(c.Dir, c.Key+".json")
+}
+
+// now returns the current time.
+func (c *FileTokenCache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// load returns the cached token if there is one which doesn't expire soon.
+func (c *FileTokenCache) load() (string, bool) {
+	info, err := os.Stat(c.path())
+	if err != nil {
+		return "", false
+	}
+	// a token which others could have read or replaced isn't used.
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		c.Logger.Debug().Str("file", c.path()).Msg("Ignoring cached token with too open permissions.")
+		return "", false
+	}
+	content, err := os.ReadFile(c.path())
+	if err != nil {
+		return "", false
+	}
+	var cached cachedToken
+	if err := json.Unmarshal(content, &cached); err != nil || cached.Token == "" {
+		return "", false
+	}
+	if !c.now().Add(tokenExpiryMargin).Before(cached.Expires) {
+		return "", false
+	}
+	return cached.Token, true
+}
+
+// store writes the cache file, readable by the current user only. It's replaced atomically, so
+// other processes never read a partial file.
+func (c *FileTokenCache) store(cached cachedToken) error {
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return err
+	}
+	content, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.Dir, c.Key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(0o600); err != nil && runtime.GOOS != "windows" {
+		_ = f.Close()
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path())
+}
+
+// tokenExpiry returns the expiry of a JWT.
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.ExpiresAt, 0), true
+}
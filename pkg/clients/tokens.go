@@ -0,0 +1,76 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// TokenSource provides the bearer tokens requests are authenticated with. The handler caches the
+// token and asks for a new one once the server rejects it.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// apiKeyTokenSource exchanges the email and API key of the handler for a token.
+type apiKeyTokenSource struct {
+	handler *KrokHandler
+}
+
+// Token implements TokenSource.
+func (s *apiKeyTokenSource) Token(ctx context.Context) (string, error) {
+	return s.handler.authenticate(ctx)
+}
+
+// StaticToken is a token which has been issued up front, for example by the user client's Generate.
+type StaticToken string
+
+// Token implements TokenSource.
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// TokenFile is the path of a file containing a token. The file is read again whenever a new token is
+// needed, so another process can keep it up to date.
+type TokenFile string
+
+// Token implements TokenSource.
+func (f TokenFile) Token(context.Context) (string, error) {
+	content, err := os.ReadFile(string(f))
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to read token file: %w", ErrAuthentication, err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("%w: token file %s is empty", ErrAuthentication, string(f))
+	}
+	return token, nil
+}
+
+// TokenCommand is a shell command which prints a token, like a helper for single sign-on.
+// It runs whenever a new token is needed.
+type TokenCommand string
+
+// Token implements TokenSource.
+func (c TokenCommand) Token(ctx context.Context) (string, error) {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, shell, flag, string(c))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: token command failed: %w: %s", ErrAuthentication, err, strings.TrimSpace(stderr.String()))
+	}
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("%w: token command printed no token", ErrAuthentication)
+	}
+	return token, nil
+}
@@ -8,7 +8,6 @@ import (
 	"net/url"
 	"path"
 	"strconv"
-	"time"
 
 	"github.com/krok-o/krok/pkg/models"
 	"github.com/rs/zerolog"
@@ -17,9 +16,8 @@ import (
 )
 
 const (
-	timeOutInSeconds = 10
-	userURI          = "/rest/api/1/krok/user"
-	usersURI         = "/rest/api/1/krok/users"
+	userURI  = "/rest/api/1/krok/user"
+	usersURI = "/rest/api/1/krok/users"
 )
 
 // NewClient creates a new user provider.
@@ -39,8 +37,8 @@ type Client struct {
 }
 
 // Create creates a user resource.
-func (c *Client) Create(user *models.User) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(user)
@@ -70,8 +68,8 @@ func (c *Client) Create(user *models.User) (*models.User, error) {
 }
 
 // Get returns a user resource.
-func (c *Client) Get(id int) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Get(ctx context.Context, id int) (*models.User, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -95,8 +93,8 @@ func (c *Client) Get(id int) (*models.User, error) {
 }
 
 // Update updates a user resource.
-func (c *Client) Update(user *models.User) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Update(ctx context.Context, user *models.User) (*models.User, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(user)
@@ -126,8 +124,8 @@ func (c *Client) Update(user *models.User) (*models.User, error) {
 }
 
 // Delete deletes a user resource.
-func (c *Client) Delete(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Delete(ctx context.Context, id int) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -149,8 +147,8 @@ func (c *Client) Delete(id int) error {
 }
 
 // List users.
-func (c *Client) List() ([]*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) List(ctx context.Context) ([]*models.User, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -174,8 +172,8 @@ func (c *Client) List() ([]*models.User, error) {
 }
 
 // Generate creates a new login token for the current user.
-func (c *Client) Generate() (map[string]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Generate(ctx context.Context) (map[string]string, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"net/url"
 	"path"
-	"time"
 
 	"github.com/rs/zerolog"
 
@@ -17,9 +16,8 @@ import (
 )
 
 const (
-	timeOutInSeconds = 10
-	vaultURI         = "/rest/api/1/krok/vault/secret"
-	vaultListURI     = "/rest/api/1/krok/vault/secrets"
+	vaultURI     = "/rest/api/1/krok/vault/secret"
+	vaultListURI = "/rest/api/1/krok/vault/secrets"
 )
 
 // NewClient creates a new vault provider.
@@ -39,8 +37,8 @@ type Client struct {
 }
 
 // Create creates a vault secret resource.
-func (c *Client) Create(setting *models.VaultSetting) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Create(ctx context.Context, setting *models.VaultSetting) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(setting)
@@ -69,8 +67,8 @@ func (c *Client) Create(setting *models.VaultSetting) error {
 }
 
 // Update updates a vault secret resource.
-func (c *Client) Update(setting *models.VaultSetting) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Update(ctx context.Context, setting *models.VaultSetting) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(setting)
@@ -99,8 +97,8 @@ func (c *Client) Update(setting *models.VaultSetting) error {
 }
 
 // Get returns a secret resource.
-func (c *Client) Get(name string) (*models.VaultSetting, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Get(ctx context.Context, name string) (*models.VaultSetting, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -124,8 +122,8 @@ func (c *Client) Get(name string) (*models.VaultSetting, error) {
 }
 
 // Delete deletes a secret resource.
-func (c *Client) Delete(name string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Delete(ctx context.Context, name string) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -148,8 +146,8 @@ func (c *Client) Delete(name string) error {
 }
 
 // List secrets.
-func (c *Client) List() ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) List(ctx context.Context) ([]string, error) {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	u, err := url.Parse(c.Address)
@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"net/url"
 	"path"
-	"time"
 
 	"github.com/rs/zerolog"
 
@@ -16,10 +15,7 @@ import (
 	"github.com/krok-o/terraform-provider-krok/pkg/clients"
 )
 
-const (
-	timeOutInSeconds = 10
-	vcsURI           = "/rest/api/1/krok/vcs-token"
-)
+const vcsURI = "/rest/api/1/krok/vcs-token"
 
 // NewClient creates a new repository provider.
 func NewClient(address string, log zerolog.Logger, handler clients.Handler) *Client {
@@ -38,8 +34,8 @@ type Client struct {
 }
 
 // Create creates a vcs token.
-func (c *Client) Create(req *models.VCSToken) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOutInSeconds)*time.Second)
+func (c *Client) Create(ctx context.Context, req *models.VCSToken) error {
+	ctx, cancel := clients.WithTimeout(ctx)
 	defer cancel()
 
 	b, err := json.Marshal(req)
@@ -0,0 +1,307 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/rs/zerolog"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/krok-o/krok/pkg/models"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+)
+
+const (
+	repositoryResourceType     = "krok_repository"
+	commandResourceType        = "krok_command"
+	commandSettingResourceType = "krok_command_setting"
+)
+
+// Generator walks an existing Krok server and turns the found entities into Terraform configuration.
+type Generator struct {
+	Client *pkg.KrokClient
+	Logger zerolog.Logger
+
+	names map[string]struct{}
+}
+
+// Output contains the generated files.
+type Output struct {
+	// Config contains the resource and variable definitions.
+	Config []byte
+	// Imports contains import blocks (Terraform >= 1.5) matching every generated resource.
+	Imports []byte
+	// ImportScript contains `terraform import` commands for older Terraform versions.
+	ImportScript []byte
+	// Variables contains values for the generated sensitive variables in tfvars format.
+	Variables []byte
+}
+
+// resource is a single generated resource together with the ID it should be imported from.
+type resource struct {
+	kind string
+	name string
+	id   string
+}
+
+// variable is a sensitive input variable holding a value which should not be inlined in the configuration.
+type variable struct {
+	name  string
+	value string
+}
+
+// NewGenerator creates a new generator.
+func NewGenerator(client *pkg.KrokClient, log zerolog.Logger) *Generator {
+	return &Generator{
+		Client: client,
+		Logger: log,
+		names:  make(map[string]struct{}),
+	}
+}
+
+// Generate lists all commands, their settings and repositories and creates configuration with
+// cross-references between them instead of raw IDs.
+func (g *Generator) Generate(ctx context.Context) (*Output, error) {
+	var (
+		resources []resource
+		variables []variable
+	)
+	config := hclwrite.NewEmptyFile()
+	body := config.Body()
+
+	commands, err := g.Client.CommandClient.List(ctx, &models.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commands: %w", err)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].ID < commands[j].ID
+	})
+	commandNames := make(map[int]string, len(commands))
+	for _, c := range commands {
+		name := g.uniqueName(c.Name)
+		commandNames[c.ID] = name
+		g.writeCommand(body, name, c)
+		resources = append(resources, resource{kind: commandResourceType, name: name, id: strconv.Itoa(c.ID)})
+
+		settings, err := g.Client.SettingsClient.List(ctx, c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list settings for command %d: %w", c.ID, err)
+		}
+		sort.Slice(settings, func(i, j int) bool {
+			return settings[i].ID < settings[j].ID
+		})
+		for _, s := range settings {
+			settingName := g.uniqueName(name + "_" + s.Key)
+			var v *variable
+			if s.InVault {
+				v = &variable{name: settingName, value: s.Value}
+				variables = append(variables, *v)
+			}
+			g.writeCommandSetting(body, settingName, name, s, v)
+			resources = append(resources, resource{kind: commandSettingResourceType, name: settingName, id: strconv.Itoa(s.ID)})
+		}
+	}
+
+	repositories, err := g.Client.RepositoryClient.List(ctx, &models.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repositories: %w", err)
+	}
+	sort.Slice(repositories, func(i, j int) bool {
+		return repositories[i].ID < repositories[j].ID
+	})
+	for _, r := range repositories {
+		// the list call doesn't return relationships and auth information, so fetch each repository in full.
+		repo, err := g.Client.RepositoryClient.Get(ctx, r.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get repository %d: %w", r.ID, err)
+		}
+		name := g.uniqueName(repo.Name)
+		secret := variable{name: name + "_webhook_secret"}
+		if repo.Auth != nil {
+			secret.value = repo.Auth.Secret
+		}
+		variables = append(variables, secret)
+		g.writeRepository(body, name, repo, commandNames, secret)
+		resources = append(resources, resource{kind: repositoryResourceType, name: name, id: strconv.Itoa(repo.ID)})
+	}
+
+	for _, v := range variables {
+		body.AppendNewline()
+		block := body.AppendNewBlock("variable", []string{v.name})
+		block.Body().SetAttributeTraversal("type", hcl.Traversal{hcl.TraverseRoot{Name: "string"}})
+		block.Body().SetAttributeValue("sensitive", cty.True)
+	}
+
+	imports := hclwrite.NewEmptyFile()
+	script := &strings.Builder{}
+	script.WriteString("#!/usr/bin/env bash\nset -e\n\n")
+	for i, r := range resources {
+		if i > 0 {
+			imports.Body().AppendNewline()
+		}
+		block := imports.Body().AppendNewBlock("import", nil)
+		block.Body().SetAttributeTraversal("to", hcl.Traversal{
+			hcl.TraverseRoot{Name: r.kind},
+			hcl.TraverseAttr{Name: r.name},
+		})
+		block.Body().SetAttributeValue("id", cty.StringVal(r.id))
+		fmt.Fprintf(script, "terraform import %s.%s %s\n", r.kind, r.name, r.id)
+	}
+
+	vars := hclwrite.NewEmptyFile()
+	for _, v := range variables {
+		vars.Body().SetAttributeValue(v.name, cty.StringVal(v.value))
+	}
+
+	g.Logger.Debug().Int("commands", len(commands)).Int("repositories", len(repositories)).Msg("Generated configuration.")
+	return &Output{
+		Config:       config.Bytes(),
+		Imports:      imports.Bytes(),
+		ImportScript: []byte(script.String()),
+		Variables:    vars.Bytes(),
+	}, nil
+}
+
+// writeCommand appends a krok_command resource block to body.
+func (g *Generator) writeCommand(body *hclwrite.Body, name string, command *models.Command) {
+	if len(body.Blocks()) > 0 {
+		body.AppendNewline()
+	}
+	block := body.AppendNewBlock("resource", []string{commandResourceType, name}).Body()
+	block.SetAttributeValue("name", cty.StringVal(command.Name))
+	block.SetAttributeValue("image", cty.StringVal(command.Image))
+	if command.Schedule != "" {
+		block.SetAttributeValue("schedule", cty.StringVal(command.Schedule))
+	}
+	block.SetAttributeValue("enabled", cty.BoolVal(command.Enabled))
+	platforms := make([]cty.Value, 0, len(command.Platforms))
+	for _, p := range command.Platforms {
+		platforms = append(platforms, cty.NumberIntVal(int64(p.ID)))
+	}
+	block.SetAttributeValue("platforms", listValue(platforms))
+}
+
+// writeCommandSetting appends a krok_command_setting resource block to body. If v is set the value
+// is referenced from that variable instead of being inlined.
+func (g *Generator) writeCommandSetting(body *hclwrite.Body, name, commandName string, setting *models.CommandSetting, v *variable) {
+	body.AppendNewline()
+	block := body.AppendNewBlock("resource", []string{commandSettingResourceType, name}).Body()
+	block.SetAttributeTraversal("command_id", hcl.Traversal{
+		hcl.TraverseRoot{Name: commandResourceType},
+		hcl.TraverseAttr{Name: commandName},
+		hcl.TraverseAttr{Name: "id"},
+	})
+	block.SetAttributeValue("key", cty.StringVal(setting.Key))
+	if v != nil {
+		block.SetAttributeTraversal("value", variableTraversal(v.name))
+	} else {
+		block.SetAttributeValue("value", cty.StringVal(setting.Value))
+	}
+	block.SetAttributeValue("in_vault", cty.BoolVal(setting.InVault))
+}
+
+// writeRepository appends a krok_repository resource block to body. Commands which are known are
+// referenced by their resource address, unknown ones are kept as raw IDs. Commands are always set, like
+// the ones of an imported repository, so the first plan is clean.
+func (g *Generator) writeRepository(body *hclwrite.Body, name string, repo *models.Repository, commandNames map[int]string, secret variable) {
+	body.AppendNewline()
+	block := body.AppendNewBlock("resource", []string{repositoryResourceType, name}).Body()
+	block.SetAttributeValue("name", cty.StringVal(repo.Name))
+	block.SetAttributeValue("url", cty.StringVal(repo.URL))
+	block.SetAttributeValue("vcs", cty.NumberIntVal(int64(repo.VCS)))
+	elems := make([]hclwrite.Tokens, 0, len(repo.Commands))
+	for _, c := range repo.Commands {
+		if n, ok := commandNames[c.ID]; ok {
+			elems = append(elems, hclwrite.TokensForTraversal(hcl.Traversal{
+				hcl.TraverseRoot{Name: commandResourceType},
+				hcl.TraverseAttr{Name: n},
+				hcl.TraverseAttr{Name: "id"},
+			}))
+		} else {
+			g.Logger.Warn().Int("command", c.ID).Str("repository", repo.Name).Msg("Command not found in command list, using raw ID.")
+			elems = append(elems, hclwrite.TokensForValue(cty.NumberIntVal(int64(c.ID))))
+		}
+	}
+	block.SetAttributeRaw("commands", tupleTokens(elems))
+	events := make([]cty.Value, 0, len(repo.Events))
+	for _, e := range repo.Events {
+		events = append(events, cty.StringVal(e))
+	}
+	block.SetAttributeValue("events", listValue(events))
+	block.SetAttributeRaw("auth", objectTokens("secret", hclwrite.TokensForTraversal(variableTraversal(secret.name))))
+	if repo.GitLab != nil && repo.GitLab.ProjectID != 0 {
+		block.SetAttributeValue("gitlab", cty.ObjectVal(map[string]cty.Value{
+			"project_id": cty.NumberIntVal(int64(repo.GitLab.ProjectID)),
+		}))
+	}
+}
+
+var invalidNameCharacters = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// uniqueName creates a valid, unique Terraform identifier out of a Krok entity name.
+func (g *Generator) uniqueName(name string) string {
+	n := strings.Trim(invalidNameCharacters.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if n == "" || !hclsyntax.ValidIdentifier(n) || (n[0] >= '0' && n[0] <= '9') {
+		n = "krok_" + n
+	}
+	candidate := n
+	for i := 2; ; i++ {
+		if _, ok := g.names[candidate]; !ok {
+			break
+		}
+		candidate = fmt.Sprintf("%s_%d", n, i)
+	}
+	g.names[candidate] = struct{}{}
+	return candidate
+}
+
+// variableTraversal returns a reference to the variable with the given name.
+func variableTraversal(name string) hcl.Traversal {
+	return hcl.Traversal{
+		hcl.TraverseRoot{Name: "var"},
+		hcl.TraverseAttr{Name: name},
+	}
+}
+
+// listValue returns a list value which is also valid when there are no elements.
+func listValue(values []cty.Value) cty.Value {
+	if len(values) == 0 {
+		return cty.ListValEmpty(cty.DynamicPseudoType)
+	}
+	return cty.TupleVal(values)
+}
+
+// objectTokens returns the tokens of an object with a single attribute which isn't a literal value.
+func objectTokens(name string, value hclwrite.Tokens) hclwrite.Tokens {
+	tokens := hclwrite.Tokens{
+		{Type: hclsyntax.TokenOBrace, Bytes: []byte("{")},
+		{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
+		{Type: hclsyntax.TokenIdent, Bytes: []byte(name)},
+		{Type: hclsyntax.TokenEqual, Bytes: []byte("=")},
+	}
+	tokens = append(tokens, value...)
+	return append(tokens,
+		&hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
+		&hclwrite.Token{Type: hclsyntax.TokenCBrace, Bytes: []byte("}")},
+	)
+}
+
+// tupleTokens creates tokens for a tuple constructor out of already generated elements.
+func tupleTokens(elems []hclwrite.Tokens) hclwrite.Tokens {
+	tokens := hclwrite.Tokens{{Type: hclsyntax.TokenOBrack, Bytes: []byte("[")}}
+	for i, e := range elems {
+		if i > 0 {
+			tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenComma, Bytes: []byte(",")})
+		}
+		tokens = append(tokens, e...)
+	}
+	return append(tokens, &hclwrite.Token{Type: hclsyntax.TokenCBrack, Bytes: []byte("]")})
+}
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/krok-o/krok/pkg/models"
+
+	"github.com/krok-o/terraform-provider-krok/pkg"
+	"github.com/krok-o/terraform-provider-krok/pkg/clients/fake"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestGenerate(t *testing.T) {
+	server := fake.NewServer()
+	defer server.Close()
+	github := models.SupportedPlatforms[models.GITHUB]
+	server.AddCommand(&models.Command{ID: 1, Name: "Slack Notification", Image: "krokhook/slack-notification:v0.0.1", Schedule: "@hourly", Enabled: true, Platforms: []models.Platform{github}})
+	server.AddCommand(&models.Command{ID: 2, Name: "slack-notification", Image: "krokhook/slack-notification:v0.0.2"})
+	server.AddCommand(&models.Command{ID: 3, Name: "3rd party", Image: "alpine", Enabled: true})
+	server.AddCommandSetting(&models.CommandSetting{ID: 1, CommandID: 1, Key: "channel", Value: "#builds"})
+	server.AddCommandSetting(&models.CommandSetting{ID: 2, CommandID: 1, Key: "token", Value: "slack-token", InVault: true})
+	server.AddRepository(&models.Repository{
+		ID:     1,
+		Name:   "app",
+		URL:    "https://github.com/krok-o/app",
+		VCS:    models.GITHUB,
+		Events: []string{"push", "pull_request"},
+		Auth:   &models.Auth{Secret: "webhook-secret"},
+		// command 9 isn't listed, so it's kept as a raw ID.
+		Commands: []*models.Command{{ID: 1}, {ID: 9}},
+	})
+	server.AddRepository(&models.Repository{
+		ID:     2,
+		Name:   "gitlab app",
+		URL:    "https://gitlab.com/krok-o/app",
+		VCS:    models.GITLAB,
+		Events: []string{"PushEvents"},
+		Auth:   &models.Auth{Secret: "gitlab-secret"},
+		GitLab: &models.GitLab{ProjectID: 10},
+	})
+	client := pkg.NewKrokClient(pkg.Config{
+		Address:      server.URL,
+		Email:        fake.Email,
+		APIKeyID:     fake.APIKeyID,
+		APIKeySecret: fake.APIKeySecret,
+	}, zerolog.Nop())
+
+	output, err := NewGenerator(client, zerolog.Nop()).Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range map[string][]byte{
+		"krok.tf":          output.Config,
+		"imports.tf":       output.Imports,
+		"import.sh":        output.ImportScript,
+		"krok.auto.tfvars": output.Variables,
+	} {
+		golden := filepath.Join("testdata", name)
+		if *update {
+			if err := os.WriteFile(golden, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s differs from %s, run go test ./pkg/export -update to update it:\n%s", name, golden, got)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+#!/usr/bin/env bash
+set -e
+
+terraform import krok_command.slack_notification 1
+terraform import krok_command_setting.slack_notification_channel 1
+terraform import krok_command_setting.slack_notification_token 2
+terraform import krok_command.slack_notification_2 2
+terraform import krok_command.krok_3rd_party 3
+terraform import krok_repository.app 1
+terraform import krok_repository.gitlab_app 2
@@ -0,0 +1,34 @@
+import {
+  to = krok_command.slack_notification
+  id = "1"
+}
+
+import {
+  to = krok_command_setting.slack_notification_channel
+  id = "1"
+}
+
+import {
+  to = krok_command_setting.slack_notification_token
+  id = "2"
+}
+
+import {
+  to = krok_command.slack_notification_2
+  id = "2"
+}
+
+import {
+  to = krok_command.krok_3rd_party
+  id = "3"
+}
+
+import {
+  to = krok_repository.app
+  id = "1"
+}
+
+import {
+  to = krok_repository.gitlab_app
+  id = "2"
+}
@@ -0,0 +1,75 @@
+resource "krok_command" "slack_notification" {
+  name      = "Slack Notification"
+  image     = "krokhook/slack-notification:v0.0.1"
+  schedule  = "@hourly"
+  enabled   = true
+  platforms = [1]
+}
+
+resource "krok_command_setting" "slack_notification_channel" {
+  command_id = krok_command.slack_notification.id
+  key        = "channel"
+  value      = "#builds"
+  in_vault   = false
+}
+
+resource "krok_command_setting" "slack_notification_token" {
+  command_id = krok_command.slack_notification.id
+  key        = "token"
+  value      = var.slack_notification_token
+  in_vault   = true
+}
+
+resource "krok_command" "slack_notification_2" {
+  name      = "slack-notification"
+  image     = "krokhook/slack-notification:v0.0.2"
+  enabled   = false
+  platforms = []
+}
+
+resource "krok_command" "krok_3rd_party" {
+  name      = "3rd party"
+  image     = "alpine"
+  enabled   = true
+  platforms = []
+}
+
+resource "krok_repository" "app" {
+  name     = "app"
+  url      = "https://github.com/krok-o/app"
+  vcs      = 1
+  commands = [krok_command.slack_notification.id, 9]
+  events   = ["push", "pull_request"]
+  auth = {
+    secret = var.app_webhook_secret
+  }
+}
+
+resource "krok_repository" "gitlab_app" {
+  name     = "gitlab app"
+  url      = "https://gitlab.com/krok-o/app"
+  vcs      = 2
+  commands = []
+  events   = ["PushEvents"]
+  auth = {
+    secret = var.gitlab_app_webhook_secret
+  }
+  gitlab = {
+    project_id = 10
+  }
+}
+
+variable "slack_notification_token" {
+  type      = string
+  sensitive = true
+}
+
+variable "app_webhook_secret" {
+  type      = string
+  sensitive = true
+}
+
+variable "gitlab_app_webhook_secret" {
+  type      = string
+  sensitive = true
+}
@@ -0,0 +1,84 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+// ImagePolicy restricts the images commands may run.
+type ImagePolicy struct {
+	// AllowedRegistries are registries, optionally followed by a path like registry.example.com/team,
+	// images have to come from. Empty allows every registry.
+	AllowedRegistries []string
+	// DeniedPatterns are regular expressions matched against the fully qualified image reference,
+	// like docker.io/library/ubuntu:latest.
+	DeniedPatterns []*regexp.Regexp
+	// RequireDigest rejects images which are only referenced by tag.
+	RequireDigest bool
+}
+
+var (
+	// ErrInvalidAllowedRegistry is returned by NewImagePolicy if an allowed registry isn't valid.
+	ErrInvalidAllowedRegistry = errors.New("invalid allowed image registry")
+	// ErrInvalidDeniedPattern is returned by NewImagePolicy if a denied pattern isn't a valid regular expression.
+	ErrInvalidDeniedPattern = errors.New("invalid denied image pattern")
+)
+
+// NewImagePolicy creates an image policy. It returns an error wrapping ErrInvalidAllowedRegistry or
+// ErrInvalidDeniedPattern if one of them is invalid.
+func NewImagePolicy(allowedRegistries, deniedPatterns []string, requireDigest bool) (*ImagePolicy, error) {
+	policy := &ImagePolicy{
+		AllowedRegistries: allowedRegistries,
+		RequireDigest:     requireDigest,
+	}
+	for _, r := range allowedRegistries {
+		// a registry, optionally followed by a path, has to be the start of a valid image name.
+		if _, err := reference.ParseNormalizedNamed(strings.TrimSuffix(r, "/") + "/image"); err != nil || r == "" {
+			return nil, fmt.Errorf("%w %q: must be a registry like registry.example.com, optionally followed by a path", ErrInvalidAllowedRegistry, r)
+		}
+	}
+	for _, p := range deniedPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidDeniedPattern, p, err)
+		}
+		policy.DeniedPatterns = append(policy.DeniedPatterns, re)
+	}
+	return policy, nil
+}
+
+// Check returns an error describing why image violates the policy, or nil if it doesn't.
+func (p *ImagePolicy) Check(image string) error {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	if len(p.AllowedRegistries) > 0 && !p.allowed(named.Name()) {
+		return fmt.Errorf("image %q is not from one of the allowed registries %s", image, strings.Join(p.AllowedRegistries, ", "))
+	}
+	full := reference.TagNameOnly(named).String()
+	for _, re := range p.DeniedPatterns {
+		if re.MatchString(full) {
+			return fmt.Errorf("image %q (%s) matches the denied pattern %q", image, full, re)
+		}
+	}
+	if _, ok := named.(reference.Digested); p.RequireDigest && !ok {
+		return fmt.Errorf("image %q must be referenced by digest, like %s@sha256:<digest>", image, named.Name())
+	}
+	return nil
+}
+
+// allowed returns whether the fully qualified image name is in one of the allowed registries.
+func (p *ImagePolicy) allowed(name string) bool {
+	for _, r := range p.AllowedRegistries {
+		r = strings.TrimSuffix(r, "/")
+		if name == r || strings.HasPrefix(name, r+"/") {
+			return true
+		}
+	}
+	return false
+}
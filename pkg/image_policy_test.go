@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestImagePolicyCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		allowedRegistries []string
+		deniedPatterns    []string
+		requireDigest     bool
+		image             string
+		allowed           bool
+	}{
+		{name: "empty policy", image: "alpine", allowed: true},
+		{name: "invalid reference", image: "Alpine", allowed: false},
+		{name: "allowed registry", allowedRegistries: []string{"registry.example.com"}, image: "registry.example.com/team/command:v1", allowed: true},
+		{name: "other registry", allowedRegistries: []string{"registry.example.com"}, image: "ghcr.io/team/command:v1", allowed: false},
+		{name: "docker hub isn't implied", allowedRegistries: []string{"registry.example.com"}, image: "alpine", allowed: false},
+		{name: "docker hub short name", allowedRegistries: []string{"docker.io"}, image: "alpine", allowed: true},
+		{name: "docker hub user image", allowedRegistries: []string{"docker.io"}, image: "krokhook/slack-notification:v0.0.1", allowed: true},
+		{name: "docker hub path", allowedRegistries: []string{"docker.io/krokhook"}, image: "krokhook/slack-notification:v0.0.1", allowed: true},
+		{name: "docker hub library path", allowedRegistries: []string{"docker.io/krokhook"}, image: "alpine", allowed: false},
+		{name: "registry path", allowedRegistries: []string{"registry.example.com/team/"}, image: "registry.example.com/team/command", allowed: true},
+		{name: "registry path prefix", allowedRegistries: []string{"registry.example.com/team"}, image: "registry.example.com/teams/command", allowed: false},
+		{name: "registry with port", allowedRegistries: []string{"registry.example.com:5000"}, image: "registry.example.com:5000/command", allowed: true},
+		{name: "denied pattern", deniedPatterns: []string{`/ubuntu:`}, image: "ubuntu:22.04", allowed: false},
+		{name: "denied implicit latest", deniedPatterns: []string{`:latest$`}, image: "docker.io/library/alpine", allowed: false},
+		{name: "not denied", deniedPatterns: []string{`:latest$`}, image: "alpine:3.19", allowed: true},
+		{name: "denied in allowed registry", allowedRegistries: []string{"docker.io"}, deniedPatterns: []string{`^docker\.io/library/`}, image: "alpine:3.19", allowed: false},
+		{name: "digest required", requireDigest: true, image: "alpine:3.19", allowed: false},
+		{name: "digest", requireDigest: true, image: "alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", allowed: true},
+		{name: "tag and digest", requireDigest: true, image: "alpine:3.19@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", allowed: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, err := NewImagePolicy(tc.allowedRegistries, tc.deniedPatterns, tc.requireDigest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = policy.Check(tc.image)
+			if allowed := err == nil; allowed != tc.allowed {
+				t.Fatalf("image %s: expected allowed %t, got %v", tc.image, tc.allowed, err)
+			}
+		})
+	}
+}
+
+func TestNewImagePolicyErrors(t *testing.T) {
+	for _, tc := range []struct {
+		allowedRegistries []string
+		deniedPatterns    []string
+		want              error
+	}{
+		{allowedRegistries: []string{""}, want: ErrInvalidAllowedRegistry},
+		{allowedRegistries: []string{"https://registry.example.com"}, want: ErrInvalidAllowedRegistry},
+		{allowedRegistries: []string{"registry.example.com/Team"}, want: ErrInvalidAllowedRegistry},
+		{deniedPatterns: []string{"("}, want: ErrInvalidDeniedPattern},
+		{allowedRegistries: []string{"registry.example.com", "docker.io/krokhook"}, deniedPatterns: []string{`:latest$`}},
+	} {
+		_, err := NewImagePolicy(tc.allowedRegistries, tc.deniedPatterns, false)
+		if !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+			t.Errorf("registries %q, patterns %q: expected %v, got %v", tc.allowedRegistries, tc.deniedPatterns, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// LogLevels contains the values accepted as log level. These match the values of TF_LOG.
+var LogLevels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "OFF"}
+
+// NewLogger creates the logger shared by the provider and all the clients.
+// If level is empty, the level follows TF_LOG_PROVIDER or TF_LOG, defaulting to WARN.
+// Lines are prefixed with `[LEVEL]` so Terraform can filter them like its own logs.
+func NewLogger(level string, out io.Writer) zerolog.Logger {
+	if level == "" {
+		level = os.Getenv("TF_LOG_PROVIDER")
+	}
+	if level == "" {
+		level = os.Getenv("TF_LOG")
+	}
+	return zerolog.New(zerolog.ConsoleWriter{
+		Out:        out,
+		NoColor:    true,
+		PartsOrder: []string{zerolog.LevelFieldName, zerolog.MessageFieldName},
+		FormatLevel: func(i interface{}) string {
+			return fmt.Sprintf("[%s]", strings.ToUpper(fmt.Sprint(i)))
+		},
+	}).Level(parseLevel(level))
+}
+
+// parseLevel converts a TF_LOG value into a zerolog level.
+func parseLevel(level string) zerolog.Level {
+	switch strings.ToUpper(strings.TrimSpace(level)) {
+	case "":
+		return zerolog.WarnLevel
+	case "DEBUG":
+		return zerolog.DebugLevel
+	case "INFO":
+		return zerolog.InfoLevel
+	case "WARN":
+		return zerolog.WarnLevel
+	case "ERROR":
+		return zerolog.ErrorLevel
+	case "OFF":
+		return zerolog.Disabled
+	default:
+		// Terraform treats TRACE, JSON and any unknown value as the most verbose level.
+		return zerolog.TraceLevel
+	}
+}
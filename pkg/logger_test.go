@@ -0,0 +1,26 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestParseLevel(t *testing.T) {
+	for level, want := range map[string]zerolog.Level{
+		"":        zerolog.WarnLevel,
+		"TRACE":   zerolog.TraceLevel,
+		"DEBUG":   zerolog.DebugLevel,
+		"debug":   zerolog.DebugLevel,
+		" INFO ":  zerolog.InfoLevel,
+		"WARN":    zerolog.WarnLevel,
+		"ERROR":   zerolog.ErrorLevel,
+		"OFF":     zerolog.Disabled,
+		"JSON":    zerolog.TraceLevel,
+		"verbose": zerolog.TraceLevel,
+	} {
+		if got := parseLevel(level); got != want {
+			t.Errorf("expected %q to be %s, got %s", level, want, got)
+		}
+	}
+}
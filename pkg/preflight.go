@@ -0,0 +1,105 @@
+package pkg
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/krok-o/terraform-provider-krok/pkg/clients"
+)
+
+// MinimumServerVersion is the oldest Krok server version the provider works with.
+const MinimumServerVersion = "v0.0.10"
+
+// healthCheckTimeout is shorter than the timeout of other requests, so an unreachable endpoint is reported quickly.
+const healthCheckTimeout = 5 * time.Second
+
+// PreflightReason describes which part of the preflight check failed.
+type PreflightReason string
+
+const (
+	// PreflightUnreachable means the endpoint could not be reached at all.
+	PreflightUnreachable PreflightReason = "endpoint is unreachable"
+	// PreflightTLS means a connection was made but the TLS handshake failed.
+	PreflightTLS PreflightReason = "TLS handshake failed"
+	// PreflightCredentials means the server rejected the configured credentials.
+	PreflightCredentials PreflightReason = "credentials were rejected"
+	// PreflightIncompatible means the endpoint isn't a Krok server or its version isn't supported.
+	PreflightIncompatible PreflightReason = "server is incompatible"
+)
+
+// hints tell the user what to check for each failure reason.
+var hints = map[PreflightReason]string{
+	PreflightUnreachable:  "check the endpoint argument or KROK_ENDPOINT",
+	PreflightTLS:          "check the server certificate and that the endpoint uses the right scheme",
+	PreflightCredentials:  "check email, api_key_id and api_key_secret or KROK_EMAIL, KROK_API_KEY_ID and KROK_API_KEY_SECRET, or the token",
+	PreflightIncompatible: "check that the endpoint points to a Krok server running " + MinimumServerVersion + " or newer",
+}
+
+// PreflightError is returned when the preflight check fails.
+type PreflightError struct {
+	Reason  PreflightReason
+	Address string
+	Err     error
+}
+
+// Error implements the error interface.
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("krok server at %s: %s: %v; %s, or set skip_preflight = true to plan without a server",
+		e.Address, e.Reason, e.Err, hints[e.Reason])
+}
+
+// Unwrap returns the underlying error.
+func (e *PreflightError) Unwrap() error {
+	return e.Err
+}
+
+// Preflight verifies that the server is reachable, is a compatible Krok server and accepts
+// the configured credentials. Krok doesn't report its version, so the capabilities of
+// MinimumServerVersion are assumed.
+func (k *KrokClient) Preflight(ctx context.Context, address string) error {
+	healthCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	if code, err := k.ServerClient.Health(healthCtx); err != nil {
+		if code != 0 {
+			return &PreflightError{Reason: PreflightIncompatible, Address: address, Err: fmt.Errorf("health check failed: %w", err)}
+		}
+		return &PreflightError{Reason: transportReason(err), Address: address, Err: err}
+	}
+
+	if err := k.ServerClient.Authenticate(ctx); err != nil {
+		reason := PreflightIncompatible
+		if errors.Is(err, clients.ErrAuthentication) {
+			reason = PreflightCredentials
+		}
+		return &PreflightError{Reason: reason, Address: address, Err: err}
+	}
+	k.Logger.Debug().Msg("Preflight check passed.")
+	return nil
+}
+
+// transportReason decides whether a failed request didn't reach the server or failed during TLS.
+func transportReason(err error) PreflightReason {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		verification     *tls.CertificateVerificationError
+		recordHeader     tls.RecordHeaderError
+	)
+	switch {
+	case errors.As(err, &unknownAuthority),
+		errors.As(err, &hostname),
+		errors.As(err, &invalid),
+		errors.As(err, &verification),
+		errors.As(err, &recordHeader),
+		strings.Contains(err.Error(), "tls:"):
+		return PreflightTLS
+	default:
+		return PreflightUnreachable
+	}
+}
@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProfileFile returns the path of the Krok config file holding the profiles, which is KROK_CONFIG_FILE
+// or ~/.krok/config.
+func ProfileFile() (string, error) {
+	if f := os.Getenv("KROK_CONFIG_FILE"); f != "" {
+		return f, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the home directory: %w", err)
+	}
+	return filepath.Join(home, ".krok", "config"), nil
+}
+
+// LoadProfile returns the connection settings of the profile called name in the config file at path.
+// Profiles are sections of an ini style file:
+//
+//	[staging]
+//	endpoint       = https://krok.staging.example.com
+//	email          = admin@example.com
+//	api_key_id     = ...
+//	api_key_secret = ...
+func LoadProfile(path, name string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	profiles := make(map[string]*Config)
+	var current *Config
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = &Config{}
+			profiles[strings.TrimSpace(line[1:len(line)-1])] = current
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			return Config{}, fmt.Errorf("%s:%d: expected a [profile] or key = value", path, n)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "endpoint":
+			current.Address = value
+		case "email":
+			current.Email = value
+		case "api_key_id":
+			current.APIKeyID = value
+		case "api_key_secret":
+			current.APIKeySecret = value
+		case "token":
+			current.Token = value
+		case "token_file":
+			current.TokenFile = value
+		case "token_command":
+			current.TokenCommand = value
+		default:
+			return Config{}, fmt.Errorf("%s:%d: unknown key %q", path, n, strings.TrimSpace(key))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Config{}, fmt.Errorf("profile %q not found in %s, available profiles: %s", name, path, strings.Join(names, ", "))
+	}
+	return *profile, nil
+}
+
+// Merge returns c with every empty field set to the value of fallback.
+func (c Config) Merge(fallback Config) Config {
+	if c.Address == "" {
+		c.Address = fallback.Address
+	}
+	if c.Email == "" {
+		c.Email = fallback.Email
+	}
+	if c.APIKeyID == "" {
+		c.APIKeyID = fallback.APIKeyID
+	}
+	if c.APIKeySecret == "" {
+		c.APIKeySecret = fallback.APIKeySecret
+	}
+	if c.Token == "" {
+		c.Token = fallback.Token
+	}
+	if c.TokenFile == "" {
+		c.TokenFile = fallback.TokenFile
+	}
+	if c.TokenCommand == "" {
+		c.TokenCommand = fallback.TokenCommand
+	}
+	return c
+}
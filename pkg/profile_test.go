@@ -0,0 +1,33 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(file, []byte("[staging]\nendpoint = https://staging.example.com\n; comment\nemail=a@example.com\n\n[typo]\nemial = b@example.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadProfile(file, "staging")
+	if err == nil || !strings.Contains(err.Error(), `unknown key "emial"`) {
+		t.Fatalf("expected the unknown key to be rejected, got %v", err)
+	}
+
+	if err := os.WriteFile(file, []byte("[staging]\nendpoint = https://staging.example.com\n; comment\nemail=a@example.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadProfile(file, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Config{Address: "https://staging.example.com", Email: "a@example.com"}); cfg != want {
+		t.Fatalf("expected %+v, got %+v", want, cfg)
+	}
+	if _, err := LoadProfile(file, "production"); err == nil || !strings.Contains(err.Error(), "available profiles: staging") {
+		t.Fatalf("expected the missing profile to be reported, got %v", err)
+	}
+}